@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// 서명 키는 `$NAGASE_SECRETS_DIR/jwt-keys/{kid}.pem` 파일로 관리합니다.
+// kid가 `2006-01-02` 형태의 날짜로 시작하면 그 날짜부터 서명에 사용되고, 그 전에는 JWKS에만 공개됩니다.
+// 새 키로 교체된 이전 키는 파일을 지울 때까지 토큰 검증에 계속 사용됩니다.
+const keyReloadInterval = 10 * time.Minute
+
+type signingKey struct {
+	ID          string
+	ActivatesAt time.Time
+	Method      jwt.SigningMethod
+	PrivateKey  crypto.Signer
+}
+
+var keys []*signingKey
+var keysMutex sync.RWMutex
+
+// EdDSA(Ed25519) 서명 방식. jwt-go에서 기본으로 지원하지 않아 직접 등록합니다.
+type signingMethodEdDSA struct{}
+
+var SigningMethodEdDSA = &signingMethodEdDSA{}
+
+func (m *signingMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+func (m *signingMethodEdDSA) Verify(signingString string, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+func loadKeys(dir string) ([]*signingKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	var loaded []*signingKey
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := parseKey(strings.TrimSuffix(filepath.Base(path), ".pem"), data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		loaded = append(loaded, key)
+	}
+
+	sort.SliceStable(loaded, func(i, j int) bool { return loaded[i].ActivatesAt.Before(loaded[j].ActivatesAt) })
+	return loaded, nil
+}
+
+func parseKey(kid string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM data")
+	}
+
+	var privateKey interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		err = fmt.Errorf("unsupported PEM type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := signingKey{ID: kid}
+	if len(kid) >= 10 {
+		key.ActivatesAt, _ = time.Parse("2006-01-02", kid[:10])
+	}
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+		key.PrivateKey = k
+	case ed25519.PrivateKey:
+		key.Method = SigningMethodEdDSA
+		key.PrivateKey = k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", privateKey)
+	}
+	return &key, nil
+}
+
+// currentSigningKey는 이미 활성화된 키 중 가장 최근의 키를 반환합니다.
+func currentSigningKey() (*signingKey, error) {
+	keysMutex.RLock()
+	defer keysMutex.RUnlock()
+
+	now := time.Now()
+	for i := len(keys) - 1; i >= 0; i-- {
+		if !keys[i].ActivatesAt.After(now) {
+			return keys[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no active signing key")
+}
+
+func verificationKey(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	keysMutex.RLock()
+	defer keysMutex.RUnlock()
+	for _, key := range keys {
+		if key.ID == kid {
+			if t.Method.Alg() != key.Method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+			}
+			return key.PrivateKey.Public(), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// signToken은 현재 키로 토큰을 서명합니다. typ은 토큰 헤더의 `typ`으로, 용도가 다른 토큰을 서로 구분하는 데 사용합니다.
+func signToken(claims jwt.Claims, typ string) (string, error) {
+	key, err := currentSigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	token.Header["typ"] = typ
+	return token.SignedString(key.PrivateKey)
+}
+
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// Ed25519
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKS는 토큰 검증에 필요한 공개 키 목록을 JWK Set(RFC 7517) 형태로 반환합니다.
+func JWKS() []byte {
+	keysMutex.RLock()
+	defer keysMutex.RUnlock()
+
+	set := struct {
+		Keys []JSONWebKey `json:"keys"`
+	}{Keys: []JSONWebKey{}}
+	for _, key := range keys {
+		jwk := JSONWebKey{KeyID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+		switch publicKey := key.PrivateKey.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	data, _ := json.Marshal(set)
+	return data
+}
+
+func reloadKeys(dir string) error {
+	loaded, err := loadKeys(dir)
+	if err != nil {
+		return err
+	}
+	if len(loaded) == 0 {
+		return fmt.Errorf("no keys in %s", dir)
+	}
+
+	keysMutex.Lock()
+	keys = loaded
+	keysMutex.Unlock()
+	return nil
+}
+
+// useTemporaryKey는 서버를 재시작하면 사라지는 임시 키로 토큰을 서명하도록 합니다. 개발 환경과 테스트에서만 사용합니다.
+func useTemporaryKey() {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	keysMutex.Lock()
+	keys = []*signingKey{{ID: "temporary", Method: jwt.SigningMethodRS256, PrivateKey: privateKey}}
+	keysMutex.Unlock()
+}
+
+// LoadKeys는 `$NAGASE_SECRETS_DIR/jwt-keys`에서 서명 키를 읽고, 주기적으로 다시 읽습니다. 서버를 시작할 때 한 번 호출해야 합니다.
+// 키를 읽을 수 없으면 오류를 반환합니다. 단, NAGASE_ALLOW_TEMPORARY_JWT_KEY가 true인 개발 환경에서는 임시 키를 사용합니다.
+func LoadKeys() error {
+	secretPath := os.Getenv("NAGASE_SECRETS_DIR")
+	if secretPath == "" {
+		secretPath = "secrets"
+	}
+	keyDir := filepath.Join(secretPath, "jwt-keys")
+
+	if err := reloadKeys(keyDir); err != nil {
+		if os.Getenv("NAGASE_ALLOW_TEMPORARY_JWT_KEY") != "true" {
+			return fmt.Errorf("failed to load JWT signing keys: %v", err)
+		}
+
+		// Tokens signed with the temporary key become invalid when the server restarts.
+		fmt.Println("Failed to load JWT signing keys, using a temporary key:", err)
+		useTemporaryKey()
+		return nil
+	}
+
+	// Reload keys periodically to pick up newly provisioned keys without restarting.
+	go func() {
+		for range time.Tick(keyReloadInterval) {
+			if err := reloadKeys(keyDir); err != nil {
+				fmt.Println("Failed to reload JWT signing keys:", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod { return SigningMethodEdDSA })
+}
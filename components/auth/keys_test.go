@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bouk/monkey"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// writeTestKeys writes an RSA key activated on 2018-09-01 and an Ed25519 key activated on 2018-10-01.
+func writeTestKeys(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "jwt-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	ioutil.WriteFile(filepath.Join(dir, "2018-09-01.pem"), rsaPEM, 0600)
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	edBytes, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	edPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edBytes})
+	ioutil.WriteFile(filepath.Join(dir, "2018-10-01-ed25519.pem"), edPEM, 0600)
+
+	return dir
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir := writeTestKeys(t)
+	defer os.RemoveAll(dir)
+
+	loaded, err := loadKeys(dir)
+	if err != nil || len(loaded) != 2 {
+		t.FailNow()
+	}
+	if loaded[0].ID != "2018-09-01" || loaded[0].Method.Alg() != "RS256" || !loaded[0].ActivatesAt.Equal(time.Date(2018, 9, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fail()
+	}
+	if loaded[1].ID != "2018-10-01-ed25519" || loaded[1].Method.Alg() != "EdDSA" {
+		t.Fail()
+	}
+
+	// Test should be failed : not a PEM file
+	ioutil.WriteFile(filepath.Join(dir, "broken.pem"), []byte("not a key"), 0600)
+	_, err = loadKeys(dir)
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := writeTestKeys(t)
+	defer os.RemoveAll(dir)
+
+	previousKeys := keys
+	defer func() { keys = previousKeys }()
+	if err := reloadKeys(dir); err != nil {
+		t.FailNow()
+	}
+
+	// Sign with the RSA key just before the Ed25519 key is activated.
+	rotatedAt := time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC)
+	patch := monkey.Patch(time.Now, func() time.Time { return rotatedAt.Add(-10 * time.Minute) })
+	oldToken, _ := GenerateToken("00000000-0000-0000-0000-000000000000", "11111111-1111-1111-1111-111111111111", 0, false)
+	token, _, _ := new(jwt.Parser).ParseUnverified(oldToken, &AuthClaims{})
+	if token.Header["kid"] != "2018-09-01" || token.Header["alg"] != "RS256" {
+		t.Fail()
+	}
+
+	// After rotation, new tokens are signed with the Ed25519 key, and old tokens remain valid.
+	patch = monkey.Patch(time.Now, func() time.Time { return rotatedAt.Add(10 * time.Minute) })
+	newToken, _ := GenerateToken("00000000-0000-0000-0000-000000000000", "11111111-1111-1111-1111-111111111111", 0, false)
+	token, _, _ = new(jwt.Parser).ParseUnverified(newToken, &AuthClaims{})
+	if token.Header["kid"] != "2018-10-01-ed25519" || token.Header["alg"] != "EdDSA" {
+		t.Fail()
+	}
+	if _, err := ValidatedToken(oldToken); err != nil {
+		t.Fail()
+	}
+	if _, err := ValidatedToken(newToken); err != nil {
+		t.Fail()
+	}
+
+	// Test should be failed : signing key is unknown
+	keys = previousKeys
+	if _, err := ValidatedToken(newToken); err == nil {
+		t.Fail()
+	}
+
+	defer patch.Unpatch()
+}
+
+func TestJWKS(t *testing.T) {
+	dir := writeTestKeys(t)
+	defer os.RemoveAll(dir)
+
+	previousKeys := keys
+	defer func() { keys = previousKeys }()
+	reloadKeys(dir)
+
+	var set struct {
+		Keys []JSONWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(JWKS(), &set); err != nil || len(set.Keys) != 2 {
+		t.FailNow()
+	}
+	if set.Keys[0].KeyType != "RSA" || set.Keys[0].N == "" || set.Keys[0].E != "AQAB" {
+		t.Fail()
+	}
+	if set.Keys[1].KeyType != "OKP" || set.Keys[1].Curve != "Ed25519" || set.Keys[1].X == "" {
+		t.Fail()
+	}
+}
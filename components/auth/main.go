@@ -1,52 +1,125 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
-var hmacSecret []byte
+// Access token은 짧게 유지하고, 세션은 refresh token으로 연장합니다.
+const AccessTokenLifetime = 30 * time.Minute
+const RefreshTokenLifetime = 30 * 24 * time.Hour
+
+// Access token과 challenge token은 같은 키로 서명하므로, 헤더의 `typ`과 `aud` claim으로 구분합니다.
+// 다른 서비스에서 access token을 검증할 때에도 두 값을 반드시 확인해야 합니다.
+const (
+	AccessTokenType     = "at+jwt"
+	AccessTokenAudience = "PoolC/Nagase"
+
+	challengeTokenType           = "challenge+jwt"
+	challengeTokenAudiencePrefix = "PoolC/Nagase/challenge/"
+)
 
 type AuthClaims struct {
-	MemberUUID string `json:"member_uuid"`
-	IsAdmin    bool   `json:"is_admin"`
+	MemberUUID   string `json:"member_uuid"`
+	SessionUUID  string `json:"session_uuid"`
+	TokenVersion int    `json:"token_version"`
+	IsAdmin      bool   `json:"is_admin"`
 
 	jwt.StandardClaims
 }
 
-func GenerateToken(memberUUID string, isAdmin bool) (string, error) {
+// GenerateToken은 access token을 발급합니다.
+// tokenVersion은 회원의 현재 토큰 버전으로, 버전이 바뀌면 이전에 발급된 토큰은 거부됩니다.
+func GenerateToken(memberUUID string, sessionUUID string, tokenVersion int, isAdmin bool) (string, error) {
 	claim := AuthClaims{
 		memberUUID,
+		sessionUUID,
+		tokenVersion,
 		isAdmin,
 		jwt.StandardClaims{
+			Audience:  AccessTokenAudience,
 			Issuer:    "PoolC/Nagase",
 			IssuedAt:  time.Now().Unix(),
-			ExpiresAt: time.Now().AddDate(0, 0, 7).Unix(),
+			ExpiresAt: time.Now().Add(AccessTokenLifetime).Unix(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claim)
-	return token.SignedString(hmacSecret)
+	return signToken(claim, AccessTokenType)
 }
 
-func ValidatedToken(tokenString string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &AuthClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return hmacSecret, nil
-	})
+func ValidatedToken(tokenString string) (*AuthClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &AuthClaims{}, verificationKey)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*AuthClaims); ok && token.Valid && token.Header["typ"] == AccessTokenType &&
+		claims.VerifyAudience(AccessTokenAudience, true) && claims.SessionUUID != "" {
+		return claims, nil
+	} else {
+		return nil, fmt.Errorf("invalid token")
+	}
+}
+
+// ChallengeClaims는 로그인 2단계 인증처럼 특정 용도(purpose)로만 쓰이는 짧은 토큰의 claim입니다.
+// `typ`과 `aud`가 access token과 다르므로 access token으로는 사용할 수 없습니다.
+type ChallengeClaims struct {
+	MemberUUID string            `json:"member_uuid"`
+	Purpose    string            `json:"purpose"`
+	Data       map[string]string `json:"data,omitempty"`
+
+	jwt.StandardClaims
+}
+
+// GenerateChallengeToken은 purpose 용도로만 쓸 수 있는 토큰을 발급합니다.
+// data는 서명되지만 암호화되지는 않으므로, 노출되면 안 되는 값을 넣어서는 안 됩니다.
+func GenerateChallengeToken(memberUUID string, purpose string, data map[string]string, lifetime time.Duration) (string, error) {
+	claim := ChallengeClaims{
+		memberUUID,
+		purpose,
+		data,
+		jwt.StandardClaims{
+			Audience:  challengeTokenAudiencePrefix + purpose,
+			Issuer:    "PoolC/Nagase",
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: time.Now().Add(lifetime).Unix(),
+		},
+	}
+
+	return signToken(claim, challengeTokenType)
+}
+
+func ValidatedChallengeToken(tokenString string, purpose string) (*ChallengeClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &ChallengeClaims{}, verificationKey)
+	if err != nil {
+		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*AuthClaims); ok && token.Valid {
-		return claims.MemberUUID, nil
+	if claims, ok := token.Claims.(*ChallengeClaims); ok && token.Valid && token.Header["typ"] == challengeTokenType &&
+		claims.VerifyAudience(challengeTokenAudiencePrefix+purpose, true) && claims.Purpose == purpose {
+		return claims, nil
 	} else {
-		return "", fmt.Errorf("invalid token")
+		return nil, fmt.Errorf("invalid token")
+	}
+}
+
+// GenerateRefreshToken은 세션 연장에 사용할 불투명한(opaque) 토큰을 생성합니다.
+// DB에는 토큰 원문 대신 HashRefreshToken의 결과만 저장해야 합니다.
+func GenerateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-func init() {
-	hmacSecret = []byte(os.Getenv("NAGASE_SECRET_KEY"))
+func HashRefreshToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
 }
@@ -0,0 +1,390 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// 모르는 kid의 토큰을 받았을 때 JWKS를 다시 받아오는 최소 간격
+const jwksRefreshInterval = time.Minute
+
+// Provider는 외부 로그인 제공자 설정입니다.
+// Issuer가 있으면 OpenID Connect discovery로 엔드포인트를 찾아 ID Token을 검증하고,
+// Issuer가 없으면(GitHub 등 OAuth2만 지원하는 경우) UserinfoEndpoint로 사용자 정보를 조회합니다.
+type Provider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	UserinfoEndpoint      string
+	JWKSURI               string
+
+	HTTPClient *http.Client
+
+	discoverOnce sync.Once
+	discoverErr  error
+	jwksMutex    sync.Mutex
+	jwks         map[string]*rsa.PublicKey
+	jwksFetched  time.Time
+}
+
+// Identity는 외부 로그인 제공자가 확인해 준 사용자 정보입니다.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+}
+
+type idTokenClaims struct {
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+
+	jwt.StandardClaims
+}
+
+// 환경변수 NAGASE_OIDC_PROVIDERS에 지정된 로그인 제공자 목록
+var Providers = map[string]*Provider{}
+
+// 자주 쓰는 제공자의 기본 설정
+type preset struct {
+	Issuer                string
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	UserinfoEndpoint      string
+	Scopes                []string
+}
+
+var presets = map[string]preset{
+	"google": {
+		Issuer: "https://accounts.google.com",
+		Scopes: []string{"openid", "email", "profile"},
+	},
+	"github": {
+		AuthorizationEndpoint: "https://github.com/login/oauth/authorize",
+		TokenEndpoint:         "https://github.com/login/oauth/access_token",
+		UserinfoEndpoint:      "https://api.github.com/user",
+		Scopes:                []string{"read:user", "user:email"},
+	},
+}
+
+// NewVerifier는 PKCE(RFC 7636)의 code verifier와 S256 code challenge를 생성합니다.
+func NewVerifier() (verifier string, challenge string, err error) {
+	verifier, err = RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	hash := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(hash[:]), nil
+}
+
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (p *Provider) client() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (p *Provider) isOIDC() bool {
+	return p.Issuer != ""
+}
+
+// discover는 `{issuer}/.well-known/openid-configuration`에서 엔드포인트를 읽어옵니다. 처음 한 번만 요청합니다.
+func (p *Provider) discover() error {
+	p.discoverOnce.Do(func() {
+		if !p.isOIDC() || p.AuthorizationEndpoint != "" {
+			return
+		}
+
+		var config struct {
+			Issuer                string `json:"issuer"`
+			AuthorizationEndpoint string `json:"authorization_endpoint"`
+			TokenEndpoint         string `json:"token_endpoint"`
+			UserinfoEndpoint      string `json:"userinfo_endpoint"`
+			JWKSURI               string `json:"jwks_uri"`
+		}
+		if err := p.getJSON(strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", "", &config); err != nil {
+			p.discoverErr = err
+			return
+		}
+		if config.Issuer != p.Issuer {
+			p.discoverErr = fmt.Errorf("issuer mismatch: %s", config.Issuer)
+			return
+		}
+
+		p.AuthorizationEndpoint = config.AuthorizationEndpoint
+		p.TokenEndpoint = config.TokenEndpoint
+		p.UserinfoEndpoint = config.UserinfoEndpoint
+		p.JWKSURI = config.JWKSURI
+	})
+	return p.discoverErr
+}
+
+// AuthCodeURL은 사용자를 보낼 로그인 제공자의 인가(authorization) 페이지 주소를 반환합니다.
+func (p *Provider) AuthCodeURL(state string, nonce string, codeChallenge string) (string, error) {
+	if err := p.discover(); err != nil {
+		return "", err
+	}
+
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", p.ClientID)
+	values.Set("redirect_uri", p.RedirectURL)
+	values.Set("scope", strings.Join(p.Scopes, " "))
+	values.Set("state", state)
+	values.Set("code_challenge", codeChallenge)
+	values.Set("code_challenge_method", "S256")
+	if p.isOIDC() {
+		values.Set("nonce", nonce)
+	}
+
+	separator := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.AuthorizationEndpoint + separator + values.Encode(), nil
+}
+
+// Authenticate는 인가 코드를 토큰으로 교환하고, 사용자 정보를 확인하여 반환합니다.
+func (p *Provider) Authenticate(code string, codeVerifier string, nonce string) (*Identity, error) {
+	if err := p.discover(); err != nil {
+		return nil, err
+	}
+
+	tokens, err := p.exchange(code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isOIDC() {
+		return p.verifyIDToken(tokens.IDToken, nonce)
+	}
+	return p.userinfo(tokens.AccessToken)
+}
+
+func (p *Provider) exchange(code string, codeVerifier string) (*tokenResponse, error) {
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", p.RedirectURL)
+	values.Set("client_id", p.ClientID)
+	values.Set("client_secret", p.ClientSecret)
+	values.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequest("POST", p.TokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tokens tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK || tokens.Error != "" {
+		return nil, fmt.Errorf("token exchange failed: %d %s", resp.StatusCode, tokens.Error)
+	}
+	return &tokens, nil
+}
+
+func (p *Provider) verifyIDToken(idToken string, nonce string) (*Identity, error) {
+	if idToken == "" {
+		return nil, fmt.Errorf("id_token is missing")
+	}
+
+	token, err := jwt.ParseWithClaims(idToken, &idTokenClaims{}, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != jwt.SigningMethodRS256.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+		}
+		kid, _ := t.Header["kid"].(string)
+		return p.publicKey(kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := token.Claims.(*idTokenClaims)
+	if claims.Issuer != p.Issuer || !claims.VerifyAudience(p.ClientID, true) || claims.Subject == "" {
+		return nil, fmt.Errorf("invalid id_token claims")
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("nonce mismatch")
+	}
+
+	return &Identity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// publicKey는 kid에 해당하는 공개 키를 반환합니다. 모르는 kid인 경우 키가 교체되었을 수 있으므로 JWKS를 다시 받아옵니다.
+// 위조된 토큰으로 외부 요청을 반복시킬 수 없도록, JWKS는 jwksRefreshInterval에 한 번만 다시 받아옵니다.
+func (p *Provider) publicKey(kid string) (*rsa.PublicKey, error) {
+	p.jwksMutex.Lock()
+	defer p.jwksMutex.Unlock()
+
+	if key, ok := p.jwks[kid]; ok {
+		return key, nil
+	}
+	if !p.jwksFetched.IsZero() && time.Since(p.jwksFetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	p.jwksFetched = time.Now()
+
+	var set struct {
+		Keys []struct {
+			KeyType string `json:"kty"`
+			KeyID   string `json:"kid"`
+			N       string `json:"n"`
+			E       string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(p.JWKSURI, "", &set); err != nil {
+		return nil, err
+	}
+
+	p.jwks = make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.KeyType != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		p.jwks[k.KeyID] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	if key, ok := p.jwks[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+func (p *Provider) userinfo(accessToken string) (*Identity, error) {
+	var info map[string]interface{}
+	if err := p.getJSON(p.UserinfoEndpoint, accessToken, &info); err != nil {
+		return nil, err
+	}
+
+	identity := Identity{}
+	if sub, ok := info["sub"].(string); ok {
+		identity.Subject = sub
+	} else if id, ok := info["id"].(float64); ok {
+		identity.Subject = fmt.Sprintf("%.0f", id)
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("subject is missing")
+	}
+
+	identity.Email, _ = info["email"].(string)
+	identity.EmailVerified, _ = info["email_verified"].(bool)
+	if identity.Name, _ = info["name"].(string); identity.Name == "" {
+		identity.Name, _ = info["login"].(string)
+	}
+	return &identity, nil
+}
+
+func (p *Provider) getJSON(endpoint string, accessToken string, v interface{}) error {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func init() {
+	baseURL := os.Getenv("NAGASE_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	for _, name := range strings.Split(os.Getenv("NAGASE_OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "NAGASE_OIDC_" + strings.ToUpper(name) + "_"
+		defaults := presets[name]
+		provider := Provider{
+			Name:                  name,
+			Issuer:                defaults.Issuer,
+			ClientID:              os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret:          os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:           strings.TrimSuffix(baseURL, "/") + "/auth/" + name + "/callback",
+			Scopes:                defaults.Scopes,
+			AuthorizationEndpoint: defaults.AuthorizationEndpoint,
+			TokenEndpoint:         defaults.TokenEndpoint,
+			UserinfoEndpoint:      defaults.UserinfoEndpoint,
+			HTTPClient:            &http.Client{Timeout: 10 * time.Second},
+		}
+		if issuer := os.Getenv(prefix + "ISSUER"); issuer != "" {
+			provider.Issuer = issuer
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{"openid", "email", "profile"}
+		}
+
+		Providers[name] = &provider
+	}
+}
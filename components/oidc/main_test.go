@@ -0,0 +1,207 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// mockProvider is a minimal OpenID Connect provider which issues an authorization code for a fixed user.
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	codeChallenge string
+	nonce         string
+	audience      string
+	jwksRequests  int
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := &mockProvider{key: key, audience: "nagase"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 mock.server.URL,
+			"authorization_endpoint": mock.server.URL + "/authorize",
+			"token_endpoint":         mock.server.URL + "/token",
+			"jwks_uri":               mock.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		mock.jwksRequests++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "mock-key",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		// Verify PKCE code verifier against the code challenge.
+		hash := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "mock-code" || base64.RawURLEncoding.EncodeToString(hash[:]) != mock.codeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, idTokenClaims{
+			Nonce:         mock.nonce,
+			Email:         "nagase@poolc.org",
+			EmailVerified: true,
+			Name:          "Nagase",
+			StandardClaims: jwt.StandardClaims{
+				Issuer:    mock.server.URL,
+				Subject:   "mock-subject",
+				Audience:  mock.audience,
+				ExpiresAt: time.Now().Add(time.Minute).Unix(),
+			},
+		})
+		idToken.Header["kid"] = "mock-key"
+		signed, _ := idToken.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "mock-access-token", "token_type": "Bearer", "id_token": signed})
+	})
+	mock.server = httptest.NewServer(mux)
+
+	return mock
+}
+
+// authorize simulates the user approving the login on the provider, and returns the state and nonce.
+func (mock *mockProvider) authorize(t *testing.T, authCodeURL string) (string, string) {
+	u, err := url.Parse(authCodeURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("client_id") != "nagase" {
+		t.Fail()
+	}
+
+	mock.codeChallenge = query.Get("code_challenge")
+	mock.nonce = query.Get("nonce")
+	return query.Get("state"), query.Get("nonce")
+}
+
+func TestAuthenticate(t *testing.T) {
+	mock := newMockProvider(t)
+	defer mock.server.Close()
+
+	provider := &Provider{Name: "mock", Issuer: mock.server.URL, ClientID: "nagase", RedirectURL: "http://localhost:8080/auth/mock/callback"}
+	verifier, challenge, _ := NewVerifier()
+	authCodeURL, err := provider.AuthCodeURL("mock-state", "mock-nonce", challenge)
+	if err != nil {
+		t.FailNow()
+	}
+	state, nonce := mock.authorize(t, authCodeURL)
+	if state != "mock-state" || nonce != "mock-nonce" {
+		t.Fail()
+	}
+
+	// Test should be failed : code verifier does not match
+	if _, err := provider.Authenticate("mock-code", "wrong-verifier", nonce); err == nil {
+		t.Fail()
+	}
+
+	// Test should be failed : nonce does not match
+	if _, err := provider.Authenticate("mock-code", verifier, "other-nonce"); err == nil {
+		t.Fail()
+	}
+
+	// Test should be passed
+	identity, err := provider.Authenticate("mock-code", verifier, nonce)
+	if err != nil || identity.Subject != "mock-subject" || identity.Email != "nagase@poolc.org" || !identity.EmailVerified {
+		t.Fail()
+	}
+
+	// Test should be failed : id_token was issued to another client
+	mock.audience = "other-client"
+	if _, err := provider.Authenticate("mock-code", verifier, nonce); err == nil {
+		t.Fail()
+	}
+}
+
+func TestAuthenticateWithUserinfo(t *testing.T) {
+	var codeChallenge string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			r.ParseForm()
+			hash := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+			if base64.RawURLEncoding.EncodeToString(hash[:]) != codeChallenge {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"invalid_grant"}`))
+				return
+			}
+			w.Write([]byte(`{"access_token":"mock-access-token","token_type":"bearer"}`))
+		case "/user":
+			if r.Header.Get("Authorization") != "Bearer mock-access-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"id":1234,"login":"nagase","name":null,"email":"nagase@poolc.org"}`))
+		}
+	}))
+	defer server.Close()
+
+	// OAuth2-only providers (e.g. GitHub) have no issuer, and are identified by the userinfo endpoint.
+	provider := &Provider{
+		Name:                  "mock",
+		ClientID:              "nagase",
+		AuthorizationEndpoint: server.URL + "/authorize",
+		TokenEndpoint:         server.URL + "/token",
+		UserinfoEndpoint:      server.URL + "/user",
+	}
+	verifier, challenge, _ := NewVerifier()
+	codeChallenge = challenge
+
+	identity, err := provider.Authenticate("mock-code", verifier, "")
+	if err != nil || identity.Subject != "1234" || identity.Name != "nagase" || identity.EmailVerified {
+		t.Fail()
+	}
+}
+
+func TestPublicKeyRefreshIsRateLimited(t *testing.T) {
+	mock := newMockProvider(t)
+	defer mock.server.Close()
+
+	provider := &Provider{Name: "mock", JWKSURI: mock.server.URL + "/jwks", HTTPClient: http.DefaultClient}
+	if _, err := provider.publicKey("mock-key"); err != nil || mock.jwksRequests != 1 {
+		t.Fail()
+	}
+
+	// Test should be failed : unknown key ids must not trigger another fetch right away
+	for i := 0; i < 3; i++ {
+		if _, err := provider.publicKey("forged-key"); err == nil {
+			t.Fail()
+		}
+	}
+	if mock.jwksRequests != 1 {
+		t.Fail()
+	}
+
+	// Test should be passed : keys are fetched again after the interval
+	provider.jwksFetched = time.Now().Add(-jwksRefreshInterval)
+	if _, err := provider.publicKey("forged-key"); err == nil || mock.jwksRequests != 2 {
+		t.Fail()
+	}
+}
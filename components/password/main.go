@@ -0,0 +1,121 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Params는 argon2id 해시 파라미터입니다.
+type Params struct {
+	Memory     uint32 // KiB
+	Time       uint32
+	Threads    uint8
+	SaltLength int
+	KeyLength  int
+}
+
+// DefaultParams는 새로 만드는 해시에 사용하는 파라미터입니다. (OWASP 권장값)
+// 값을 올리면, 기존 해시는 다음 로그인 때 새 파라미터로 다시 해시됩니다.
+var DefaultParams = Params{
+	Memory:     19 * 1024,
+	Time:       2,
+	Threads:    1,
+	SaltLength: 16,
+	KeyLength:  32,
+}
+
+var encoding = base64.RawStdEncoding
+
+// Hash는 비밀번호를 DefaultParams로 해시하여 PHC 문자열 형태로 반환합니다.
+// e.g. `$argon2id$v=19$m=19456,t=2,p=1${salt}${hash}`
+func Hash(password string) (string, error) {
+	salt := make([]byte, DefaultParams.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, DefaultParams.Time, DefaultParams.Memory, DefaultParams.Threads, uint32(DefaultParams.KeyLength))
+	return EncodeArgon2id(DefaultParams, salt, key), nil
+}
+
+// EncodeArgon2id는 argon2id 해시를 PHC 문자열로 만듭니다. 파라미터가 따로 저장된 기존 해시를 옮길 때 사용합니다.
+func EncodeArgon2id(params Params, salt []byte, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Time, params.Threads, encoding.EncodeToString(salt), encoding.EncodeToString(key))
+}
+
+// Verify는 비밀번호가 해시와 일치하는지 확인합니다.
+// 일치하지만 해시의 알고리즘이나 파라미터가 DefaultParams와 다른 경우, rehash가 true입니다.
+func Verify(password string, encoded string) (ok bool, rehash bool) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false
+		}
+
+		hash := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(hash, key) != 1 {
+			return false, false
+		}
+		return true, params != DefaultParams
+	case isBcrypt(encoded):
+		// Legacy hashes imported from Yuzuki.
+		if bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) != nil {
+			return false, false
+		}
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+// IsSupported는 Verify로 확인할 수 있는 형태의 해시인지 확인합니다.
+func IsSupported(encoded string) bool {
+	if isBcrypt(encoded) {
+		_, err := bcrypt.Cost([]byte(encoded))
+		return err == nil
+	}
+	_, _, _, err := decodeArgon2id(encoded)
+	return err == nil
+}
+
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func decodeArgon2id(encoded string) (Params, []byte, []byte, error) {
+	var params Params
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters")
+	}
+
+	salt, err := encoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	key, err := encoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash")
+	}
+	params.SaltLength = len(salt)
+	params.KeyLength = len(key)
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,80 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHash(t *testing.T) {
+	hashed, err := Hash("nagase-password")
+	if err != nil || !strings.HasPrefix(hashed, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.FailNow()
+	}
+
+	// Test should be passed
+	if ok, rehash := Verify("nagase-password", hashed); !ok || rehash {
+		t.Fail()
+	}
+
+	// Test should be failed : wrong password
+	if ok, _ := Verify("wrong-password", hashed); ok {
+		t.Fail()
+	}
+
+	// Test should be passed : salt is random
+	if other, _ := Hash("nagase-password"); other == hashed {
+		t.Fail()
+	}
+}
+
+func TestVerifyOutdatedParams(t *testing.T) {
+	// Hashes created before the parameters were stored with them.
+	salt := []byte("0123456789abcdef0123456789abcdef")
+	key := argon2.IDKey([]byte("nagase-password"), salt, 1, 8*1024, 4, 32)
+	hashed := EncodeArgon2id(Params{Memory: 8 * 1024, Time: 1, Threads: 4}, salt, key)
+
+	// Test should be passed : password matches, but should be rehashed
+	if ok, rehash := Verify("nagase-password", hashed); !ok || !rehash {
+		t.Fail()
+	}
+
+	// Test should be failed : wrong password
+	if ok, rehash := Verify("wrong-password", hashed); ok || rehash {
+		t.Fail()
+	}
+}
+
+func TestVerifyBcrypt(t *testing.T) {
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("yuzuki-password"), bcrypt.MinCost)
+	if !IsSupported(string(hashed)) {
+		t.Fail()
+	}
+
+	// Test should be passed : legacy hashes are always rehashed
+	if ok, rehash := Verify("yuzuki-password", string(hashed)); !ok || !rehash {
+		t.Fail()
+	}
+
+	// Test should be failed : wrong password
+	if ok, _ := Verify("wrong-password", string(hashed)); ok {
+		t.Fail()
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	for _, hashed := range []string{
+		"",
+		"plaintext",
+		"$argon2i$v=19$m=19456,t=2,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=16$m=19456,t=2,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=19456$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$",
+	} {
+		if ok, _ := Verify("", hashed); ok || IsSupported(hashed) {
+			t.Errorf("%q should not be accepted", hashed)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Shared secret of the RFC 6238 test vectors ("12345678901234567890").
+var testSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// RFC 6238 Appendix B, truncated to 6 digits.
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range vectors {
+		code, err := Code(testSecret, time.Unix(unix, 0))
+		if err != nil || code != expected {
+			t.Errorf("Code at %d = %s, expected %s", unix, code, expected)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	// Test should be passed : current, previous, and next time steps are accepted
+	for _, d := range []time.Duration{0, -Period * time.Second, Period * time.Second} {
+		code, _ := Code(testSecret, now.Add(d))
+		step, ok := Validate(testSecret, code, now)
+		if !ok || step != now.Add(d).Unix()/Period {
+			t.Fail()
+		}
+	}
+
+	// Test should be failed : code is too old
+	code, _ := Code(testSecret, now.Add(-2*Period*time.Second))
+	if _, ok := Validate(testSecret, code, now); ok {
+		t.Fail()
+	}
+
+	// Test should be failed : malformed code
+	if _, ok := Validate(testSecret, "12345", now); ok {
+		t.Fail()
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil || len(secret) != 32 {
+		t.Fail()
+	}
+	if _, err := Code(secret, time.Now()); err != nil {
+		t.Fail()
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("JBSWY3DPEHPK3PXP", "nagase")
+	if !strings.HasPrefix(uri, "otpauth://totp/PoolC:nagase?") ||
+		!strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") ||
+		!strings.Contains(uri, "issuer=PoolC") {
+		t.Fail()
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil || len(codes) != 10 {
+		t.FailNow()
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' || seen[code] {
+			t.Fail()
+		}
+		seen[code] = true
+	}
+}
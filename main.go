@@ -5,32 +5,255 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/graphql-go/graphql"
 	"github.com/graphql-go/handler"
 
 	"nagase/components/auth"
+	"nagase/components/oidc"
 	"nagase/models"
 )
 
+// bearerToken extracts the token from `Authorization: Bearer <token>` header.
+func bearerToken(authorization string) string {
+	parts := strings.SplitN(authorization, " ", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// trustedProxies are the reverse proxies allowed to set `X-Forwarded-For` header.
+// NAGASE_TRUSTED_PROXIES is a comma-separated list of IP addresses or CIDRs.
+var trustedProxies = parseTrustedProxies(os.Getenv("NAGASE_TRUSTED_PROXIES"))
+
+func parseTrustedProxies(value string) []*net.IPNet {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			proxies = append(proxies, network)
+		} else {
+			fmt.Println("Ignoring invalid trusted proxy:", entry)
+		}
+	}
+	return proxies
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client. `X-Forwarded-For` header is only respected when the request comes from
+// a trusted proxy, and the rightmost address which is not a trusted proxy is used. Returns "" if no valid address is found.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote := net.ParseIP(host)
+	if remote == nil {
+		return ""
+	}
+	if !isTrustedProxy(remote) {
+		return remote.String()
+	}
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			break
+		}
+		if !isTrustedProxy(ip) {
+			return ip.String()
+		}
+	}
+	return remote.String()
+}
+
+// handleExternalLogin handles OpenID Connect (or OAuth2) login with external providers.
+//
+//   - GET /auth/{provider}/login[?link_token=...] : redirects to the provider.
+//   - GET /auth/{provider}/callback : finishes the login, and redirects to the frontend with the result in the URL fragment.
+func handleExternalLogin(w http.ResponseWriter, r *http.Request) {
+	paths := strings.Split(r.URL.Path, "/")
+	if len(paths) != 4 || r.Method != "GET" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	providerName := paths[2]
+	provider, ok := oidc.Providers[providerName]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	frontendURL := os.Getenv("NAGASE_OIDC_REDIRECT_URL")
+	if frontendURL == "" {
+		frontendURL = "https://poolc.org/accounts/oauth"
+	}
+	redirectWithResult := func(result url.Values) {
+		http.Redirect(w, r, frontendURL+"#"+result.Encode(), http.StatusFound)
+	}
+	redirectWithError := func(code string) {
+		redirectWithResult(url.Values{"error": {code}})
+	}
+
+	switch paths[3] {
+	case "login":
+		// Link to the member who requested, if link token exists.
+		linkMemberUUID := ""
+		if linkToken := r.URL.Query().Get("link_token"); linkToken != "" {
+			memberUUID, err := models.GetMemberUUIDByExternalLinkToken(linkToken)
+			if err != nil {
+				redirectWithError("ERR401")
+				return
+			}
+			linkMemberUUID = memberUUID
+		}
+
+		// Keep state, nonce and PKCE verifier on a signed cookie until the callback.
+		state, _ := oidc.RandomString()
+		nonce, _ := oidc.RandomString()
+		verifier, challenge, err := oidc.NewVerifier()
+		if err != nil {
+			redirectWithError("ERR500")
+			return
+		}
+		data := map[string]string{"provider": providerName, "state": state, "nonce": nonce, "verifier": verifier}
+		cookie, err := auth.GenerateChallengeToken(linkMemberUUID, "oidc-state", data, 10*time.Minute)
+		if err != nil {
+			redirectWithError("ERR500")
+			return
+		}
+
+		authCodeURL, err := provider.AuthCodeURL(state, nonce, challenge)
+		if err != nil {
+			redirectWithError("OID000")
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     "nagase_oidc",
+			Value:    cookie,
+			Path:     "/auth/",
+			MaxAge:   600,
+			HttpOnly: true,
+			Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, authCodeURL, http.StatusFound)
+	case "callback":
+		cookie, err := r.Cookie("nagase_oidc")
+		if err != nil {
+			redirectWithError("OID000")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "nagase_oidc", Path: "/auth/", MaxAge: -1})
+
+		claims, err := auth.ValidatedChallengeToken(cookie.Value, "oidc-state")
+		if err != nil || claims.Data["provider"] != providerName || claims.Data["state"] != r.URL.Query().Get("state") {
+			redirectWithError("OID000")
+			return
+		}
+
+		identity, err := provider.Authenticate(r.URL.Query().Get("code"), claims.Data["verifier"], claims.Data["nonce"])
+		if err != nil {
+			redirectWithError("OID000")
+			return
+		}
+
+		ctx := context.Background()
+		ctx = context.WithValue(ctx, "userAgent", r.UserAgent())
+		ctx = context.WithValue(ctx, "ipAddress", clientIP(r))
+		token, status, err := models.LoginWithExternalIdentity(ctx, providerName, identity, claims.MemberUUID)
+		if err != nil {
+			redirectWithError(err.Error())
+			return
+		}
+
+		result := url.Values{"status": {status}}
+		if token != nil {
+			if token.Key != "" {
+				result.Set("key", token.Key)
+				result.Set("refreshToken", token.RefreshToken)
+			} else {
+				result.Set("challengeToken", token.ChallengeToken)
+				result.Set("isTwoFactorRequired", strconv.FormatBool(token.IsTwoFactorRequired))
+				result.Set("isTwoFactorEnrollmentRequired", strconv.FormatBool(token.IsTwoFactorEnrollmentRequired))
+			}
+		}
+		redirectWithResult(result)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
 func main() {
+	if err := auth.LoadKeys(); err != nil {
+		panic(err)
+	}
+
 	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
 		Query: graphql.NewObject(graphql.ObjectConfig{
 			Name: "RootQuery",
 			Fields: graphql.Fields{
-				"me":       models.MeQuery,
-				"members":  models.MembersQuery,
-				"board":    models.BoardQuery,
-				"boards":   models.BoardsQuery,
-				"post":     models.PostQuery,
-				"postPage": models.PostPageQuery,
-				"project":  models.ProjectQuery,
-				"projects": models.ProjectsQuery,
-				"vote":     models.VoteQuery,
+				"me":                         models.MeQuery,
+				"member":                     models.MemberQuery,
+				"members":                    models.MembersQuery,
+				"memberPage":                 models.MemberPageQuery,
+				"memberDirectory":            models.MemberDirectoryQuery,
+				"exportMembers":              models.ExportMembersQuery,
+				"mySessions":                 models.MySessionsQuery,
+				"myExternalIdentities":       models.MyExternalIdentitiesQuery,
+				"myPersonalAccessTokens":     models.MyPersonalAccessTokensQuery,
+				"memberPersonalAccessTokens": models.MemberPersonalAccessTokensQuery,
+				"memberSessions":             models.MemberSessionsQuery,
+				"roles":                      models.RolesQuery,
+				"permissions":                models.PermissionsQuery,
+				"inviteCodes":                models.InviteCodesQuery,
+				"applicationForm":            models.ApplicationFormQuery,
+				"applicationForms":           models.ApplicationFormsQuery,
+				"applications":               models.ApplicationsQuery,
+				"myApplications":             models.MyApplicationsQuery,
+				"terms":                      models.TermsQuery,
+				"termEnrollments":            models.TermEnrollmentsQuery,
+				"duesPayments":               models.DuesPaymentsQuery,
+				"myDataExports":              models.MyDataExportsQuery,
+				"legacyAccountClaims":        models.LegacyAccountClaimsQuery,
+				"memberGroups":               models.MemberGroupsQuery,
+				"board":                      models.BoardQuery,
+				"boards":                     models.BoardsQuery,
+				"boardCategories":            models.BoardCategoriesQuery,
+				"post":                       models.PostQuery,
+				"postPage":                   models.PostPageQuery,
+				"project":                    models.ProjectQuery,
+				"projects":                   models.ProjectsQuery,
+				"vote":                       models.VoteQuery,
 			},
 		}),
 		Mutation: graphql.NewObject(graphql.ObjectConfig{
@@ -40,14 +263,74 @@ func main() {
 				"createAccessToken":  models.CreateAccessTokenMutation,
 				"refreshAccessToken": models.RefreshAccessTokenMutation,
 
+				// Personal access tokens
+				"createPersonalAccessToken": models.CreatePersonalAccessTokenMutation,
+				"revokePersonalAccessToken": models.RevokePersonalAccessTokenMutation,
+				"setMemberServiceAccount":   models.SetMemberServiceAccountMutation,
+
+				// External identities
+				"createExternalLinkToken": models.CreateExternalLinkTokenMutation,
+				"unlinkExternalIdentity":  models.UnlinkExternalIdentityMutation,
+
+				// Two-factor authentication
+				"enrollTOTP":              models.EnrollTOTPMutation,
+				"confirmTOTP":             models.ConfirmTOTPMutation,
+				"disableTOTP":             models.DisableTOTPMutation,
+				"regenerateRecoveryCodes": models.RegenerateRecoveryCodesMutation,
+				"verifyTwoFactor":         models.VerifyTwoFactorMutation,
+				"setMemberTOTPRequired":   models.SetMemberTOTPRequiredMutation,
+
+				// Roles
+				"createRole":   models.CreateRoleMutation,
+				"updateRole":   models.UpdateRoleMutation,
+				"deleteRole":   models.DeleteRoleMutation,
+				"assignRole":   models.AssignRoleMutation,
+				"unassignRole": models.UnassignRoleMutation,
+
+				// Invite codes
+				"createInviteCode": models.CreateInviteCodeMutation,
+				"revokeInviteCode": models.RevokeInviteCodeMutation,
+
+				// Applications
+				"createApplicationForm": models.CreateApplicationFormMutation,
+				"updateApplicationForm": models.UpdateApplicationFormMutation,
+				"reviewApplication":     models.ReviewApplicationMutation,
+				"decideApplication":     models.DecideApplicationMutation,
+
+				// Terms & dues
+				"createTerm":        models.CreateTermMutation,
+				"updateTerm":        models.UpdateTermMutation,
+				"enrollMember":      models.EnrollMemberMutation,
+				"unenrollMember":    models.UnenrollMemberMutation,
+				"recordDuesPayment": models.RecordDuesPaymentMutation,
+				"deleteDuesPayment": models.DeleteDuesPaymentMutation,
+
+				// Sessions
+				"revokeSession":     models.RevokeSessionMutation,
+				"revokeAllSessions": models.RevokeAllSessionsMutation,
+
 				// Boards
-				"createBoard": models.CreateBoardMutation,
-				"updateBoard": models.UpdateBoardMutation,
-				"deleteBoard": models.DeleteBoardMutation,
+				"createBoard":            models.CreateBoardMutation,
+				"updateBoard":            models.UpdateBoardMutation,
+				"deleteBoard":            models.DeleteBoardMutation,
+				"createBoardCategory":    models.CreateBoardCategoryMutation,
+				"updateBoardCategory":    models.UpdateBoardCategoryMutation,
+				"deleteBoardCategory":    models.DeleteBoardCategoryMutation,
+				"assignBoardModerator":   models.AssignBoardModeratorMutation,
+				"unassignBoardModerator": models.UnassignBoardModeratorMutation,
+				"setBoardGroupAccess":    models.SetBoardGroupAccessMutation,
+
+				// Member groups
+				"createMemberGroup":       models.CreateMemberGroupMutation,
+				"updateMemberGroup":       models.UpdateMemberGroupMutation,
+				"deleteMemberGroup":       models.DeleteMemberGroupMutation,
+				"addMemberGroupMember":    models.AddMemberGroupMemberMutation,
+				"removeMemberGroupMember": models.RemoveMemberGroupMemberMutation,
 
 				// Comments
 				"createComment": models.CreateCommentMutation,
 				"deleteComment": models.DeleteCommentMutation,
+				"hideComment":   models.HideCommentMutation,
 
 				// Members
 				"createMember":               models.CreateMemberMutation,
@@ -57,11 +340,26 @@ func main() {
 				"toggleMemberIsActivated":    models.ToggleMemberIsActivatedMutation,
 				"toggleMemberIsAdmin":        models.ToggleMemberIsAdminMutation,
 				"requestMemberPasswordReset": models.RequestPasswordResetMutation,
+				"unlockMember":               models.UnlockMemberMutation,
+				"verifyEmail":                models.VerifyEmailMutation,
+				"requestEmailVerification":   models.RequestEmailVerificationMutation,
+				"updateMemberPrivacy":        models.UpdateMemberPrivacyMutation,
+				"updateMemberProfile":        models.UpdateMemberProfileMutation,
+				"importMembers":              models.ImportMembersMutation,
+				"withdrawMember":             models.WithdrawMemberMutation,
+				"cancelWithdrawal":           models.CancelWithdrawalMutation,
+				"requestDataExport":          models.RequestDataExportMutation,
+				"claimLegacyAccount":         models.ClaimLegacyAccountMutation,
+				"decideLegacyAccountClaim":   models.DecideLegacyAccountClaimMutation,
+				"mergeMembers":               models.MergeMembersMutation,
 
 				// Posts
 				"createPost": models.CreatePostMutation,
 				"deletePost": models.DeletePostMutation,
 				"updatePost": models.UpdatePostMutation,
+				"hidePost":   models.HidePostMutation,
+				"pinPost":    models.PinPostMutation,
+				"movePost":   models.MovePostMutation,
 
 				// Projects
 				"createProject": models.CreateProjectMutation,
@@ -97,21 +395,30 @@ func main() {
 
 		// Set context.
 		ctx := context.Background()
+		ctx = context.WithValue(ctx, "userAgent", r.UserAgent())
+		ctx = context.WithValue(ctx, "ipAddress", clientIP(r))
 		authorization := r.Header.Get("Authorization")
-		if authorization != "" {
-			memberUUID, err := auth.ValidatedToken(strings.Split(authorization, " ")[1])
+		if token := bearerToken(authorization); strings.HasPrefix(token, models.PersonalAccessTokenPrefix) {
+			// Personal access tokens are only allowed to call the APIs within their scopes,
+			// so the member is not set directly in the context.
+			personalAccessToken, err := models.GetMemberByPersonalAccessToken(token)
 			if err != nil {
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
 
-			member, err := models.GetMemberByUUID(memberUUID)
-			if err != nil || !member.IsActivated {
+			personalAccessToken.Touch()
+			ctx = context.WithValue(ctx, "personalAccessToken", personalAccessToken)
+		} else if authorization != "" {
+			member, session, err := models.GetMemberByAccessToken(token)
+			if err != nil {
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
 
+			session.Touch(clientIP(r))
 			ctx = context.WithValue(ctx, "member", member)
+			ctx = context.WithValue(ctx, "session", session)
 		}
 
 		h.ContextHandler(ctx, w, r)
@@ -148,8 +455,8 @@ func main() {
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
-			memberUUID, err := auth.ValidatedToken(strings.Split(authorization, " ")[1])
-			if memberUUID == "" || err != nil {
+			member, _, err := models.GetMemberByAccessToken(bearerToken(authorization))
+			if err != nil {
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
@@ -167,7 +474,7 @@ func main() {
 			io.Copy(&buffer, upload)
 
 			// Save file
-			err = models.SaveFile(&buffer, fileName)
+			err = models.SaveFile(&buffer, fileName, member.UUID)
 			if err != nil {
 				w.WriteHeader(http.StatusBadRequest)
 				return
@@ -180,6 +487,35 @@ func main() {
 		}
 	})))
 
+	server.Handle("/exports/", handlers.LoggingHandler(os.Stdout, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The token in the download link is the only credential for the data export.
+		paths := strings.Split(r.URL.Path, "/")
+		if len(paths) != 3 || r.Method != "GET" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		file, err := models.GetDataExportFile(paths[2])
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="poolc-data.zip"`)
+		w.Header().Set("Cache-Control", "no-store")
+		io.Copy(w, file)
+	})))
+	server.Handle("/auth/", handlers.LoggingHandler(os.Stdout, http.HandlerFunc(handleExternalLogin)))
+	server.Handle("/.well-known/jwks.json", handlers.LoggingHandler(os.Stdout, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Public keys to verify access tokens issued by this server.
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=600")
+		w.Write(auth.JWKS())
+	})))
+
 	fmt.Println("Server listening port 8080...")
 	http.ListenAndServe(":8080", handlers.CompressHandler(server))
 }
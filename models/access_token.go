@@ -5,24 +5,42 @@ import (
 
 	"github.com/graphql-go/graphql"
 
-	"nagase/components/auth"
 	"nagase/components/database"
 )
 
 type AccessToken struct {
-	Key string `json:"key"`
+	Key          string `json:"key"`
+	RefreshToken string `json:"refreshToken"`
+
+	// 2단계 인증이 필요한 경우, Key 대신 challenge token이 발급됩니다.
+	ChallengeToken                string `json:"challengeToken"`
+	IsTwoFactorRequired           bool   `json:"isTwoFactorRequired"`
+	IsTwoFactorEnrollmentRequired bool   `json:"isTwoFactorEnrollmentRequired"`
 }
 
 var accessTokenType = graphql.NewObject(graphql.ObjectConfig{
 	Name: "AccessToken",
 	Fields: graphql.Fields{
-		"key": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"key":          &graphql.Field{Type: graphql.String},
+		"refreshToken": &graphql.Field{Type: graphql.String},
+		"challengeToken": &graphql.Field{
+			Type:        graphql.String,
+			Description: "2단계 인증 또는 2단계 인증 등록에 사용할 토큰. 5분 동안 유효합니다.",
+		},
+		"isTwoFactorRequired": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.Boolean),
+			Description: "true인 경우, verifyTwoFactor mutation으로 로그인을 마쳐야 합니다.",
+		},
+		"isTwoFactorEnrollmentRequired": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.Boolean),
+			Description: "true인 경우, enrollTOTP와 confirmTOTP mutation으로 2단계 인증을 등록해야 로그인할 수 있습니다.",
+		},
 	},
 })
 
 var CreateAccessTokenMutation = &graphql.Field{
 	Type:        accessTokenType,
-	Description: "Access Token을 발급합니다.",
+	Description: "Access Token을 발급합니다. 2단계 인증을 사용하는 회원에게는 Access Token 대신 challengeToken을 발급합니다.",
 	Args: graphql.FieldConfigArgument{
 		"LoginInput": &graphql.ArgumentConfig{
 			Type: graphql.NewNonNull(graphql.NewInputObject(graphql.InputObjectConfig{
@@ -37,42 +55,51 @@ var CreateAccessTokenMutation = &graphql.Field{
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
 		loginInput := params.Args["LoginInput"].(map[string]interface{})
+		ipAddress, _ := params.Context.Value("ipAddress").(string)
 
-		// Get the member by login id and password.
+		// Get the member by login id and password, refusing repeated failures.
 		var member Member
 		database.DB.Where(&Member{LoginID: loginInput["loginID"].(string)}).First(&member)
-		if member.UUID == "" || !member.ValidatePassword(loginInput["password"].(string)) {
+		if member.UUID == "" {
+			if err := checkMemberLoginAllowed(nil, ipAddress); err != nil {
+				return nil, err
+			}
+			recordMemberLoginFailure(nil, ipAddress)
+			return nil, fmt.Errorf("TKN000")
+		}
+		if err := checkMemberLoginAllowed(&member, ipAddress); err != nil {
+			return nil, err
+		}
+		if !member.ValidatePassword(loginInput["password"].(string)) {
+			recordMemberLoginFailure(&member, ipAddress)
 			return nil, fmt.Errorf("TKN000")
 		}
 		if !member.IsActivated {
 			return nil, fmt.Errorf("TKN002")
 		}
 
-		// Generate new token and return.
-		key, err := auth.GenerateToken(member.UUID, member.IsAdmin)
+		// Start a new session (or a two-factor challenge) and return its tokens.
+		token, err := issueLoginToken(params.Context, &member)
 		if err != nil {
 			return nil, fmt.Errorf("ERR500")
 		}
 
-		return AccessToken{Key: key}, nil
+		return *token, nil
 	},
 }
 
 var RefreshAccessTokenMutation = &graphql.Field{
 	Type:        accessTokenType,
-	Description: "Access Token을 갱신합니다.",
+	Description: "Refresh Token으로 Access Token을 갱신합니다. 사용한 Refresh Token은 폐기되고 새 Refresh Token이 발급됩니다.",
+	Args: graphql.FieldConfigArgument{
+		"refreshToken": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if params.Context.Value("member") == nil {
-			return nil, fmt.Errorf("ERR401")
-		}
-		member := params.Context.Value("member").(*Member)
-
-		// Generate new token and return.
-		key, err := auth.GenerateToken(member.UUID, member.IsAdmin)
+		token, err := rotateSession(params.Context, params.Args["refreshToken"].(string))
 		if err != nil {
-			return nil, fmt.Errorf("ERR500")
+			return nil, err
 		}
 
-		return AccessToken{Key: key}, nil
+		return *token, nil
 	},
 }
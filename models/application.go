@@ -0,0 +1,621 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/jinzhu/gorm"
+
+	"nagase/components/database"
+	"nagase/components/email"
+)
+
+const (
+	ApplicationStatusPending  = "PENDING"
+	ApplicationStatusAccepted = "ACCEPTED"
+	ApplicationStatusRejected = "REJECTED"
+
+	ApplicationVoteApprove = "APPROVE"
+	ApplicationVoteReject  = "REJECT"
+	ApplicationVoteAbstain = "ABSTAIN"
+)
+
+// 결과 안내 메일의 기본 템플릿. {name}, {loginID}, {formTitle}은 지원자의 정보로 바뀝니다.
+var applicationAcceptedEmailTitle = "[PoolC] {formTitle} 결과 안내"
+var applicationAcceptedEmailBody = `
+안녕하세요 {name}님,
+PoolC {formTitle}에 지원해주셔서 감사합니다.
+
+{name}님의 가입이 승인되었습니다. 이제 {loginID} 계정으로 로그인하실 수 있습니다.
+감사합니다.
+`
+var applicationRejectedEmailTitle = "[PoolC] {formTitle} 결과 안내"
+var applicationRejectedEmailBody = `
+안녕하세요 {name}님,
+PoolC {formTitle}에 지원해주셔서 감사합니다.
+
+아쉽게도 이번에는 함께하지 못하게 되었습니다. 다음 모집 때 다시 만나뵙기를 바랍니다.
+감사합니다.
+`
+
+// ApplicationForm은 모집 기간과 지원서 질문을 담은 가입 신청서 양식입니다.
+type ApplicationForm struct {
+	ID int
+
+	Title       string `gorm:"type:varchar(255)"`
+	Description string `gorm:"type:text"`
+	OpensAt     time.Time
+	ClosesAt    time.Time
+
+	// 결과 안내 메일 템플릿. 비어 있으면 기본 템플릿을 사용합니다.
+	AcceptedEmailTitle string `gorm:"type:varchar(255)"`
+	AcceptedEmailBody  string `gorm:"type:text"`
+	RejectedEmailTitle string `gorm:"type:varchar(255)"`
+	RejectedEmailBody  string `gorm:"type:text"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (form *ApplicationForm) IsOpen() bool {
+	now := time.Now()
+	return !now.Before(form.OpensAt) && now.Before(form.ClosesAt)
+}
+
+func (form *ApplicationForm) Questions() []ApplicationQuestion {
+	var questions []ApplicationQuestion
+	database.DB.Where(&ApplicationQuestion{FormID: form.ID}).Order("position asc").Find(&questions)
+	return questions
+}
+
+type ApplicationQuestion struct {
+	ID int
+
+	FormID      int    `gorm:"INDEX"`
+	Position    int    `gorm:"NOT NULL;default:0"`
+	Text        string `gorm:"type:varchar(255)"`
+	Description string `gorm:"type:text"`
+	IsRequired  bool   `gorm:"default:false"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Application은 회원가입과 함께 제출된 가입 신청서입니다.
+type Application struct {
+	ID int
+
+	FormID     int    `gorm:"INDEX"`
+	MemberUUID string `gorm:"type:varchar(40);INDEX"`
+	Status     string `gorm:"type:varchar(20);NOT NULL;default:'PENDING'"`
+
+	DecidedByUUID string `gorm:"type:varchar(40)"`
+	DecidedAt     *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ApplicationAnswer struct {
+	ApplicationID int    `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false"`
+	QuestionID    int    `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false"`
+	Answer        string `gorm:"type:text"`
+}
+
+// ApplicationReview는 신청서에 대한 심사자의 의견과 찬반 투표입니다. 심사자마다 하나씩 남길 수 있습니다.
+type ApplicationReview struct {
+	ApplicationID int    `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false"`
+	ReviewerUUID  string `gorm:"type:varchar(40);PRIMARY_KEY"`
+	Vote          string `gorm:"type:varchar(20)"`
+	Comment       string `gorm:"type:text"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+var applicationQuestionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ApplicationQuestion",
+	Fields: graphql.Fields{
+		"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"position":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"text":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"description": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"isRequired":  &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+	},
+})
+
+var applicationFormType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ApplicationForm",
+	Fields: graphql.Fields{
+		"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"title":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"description": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"opensAt":     &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"closesAt":    &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"isOpen": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				form := params.Source.(ApplicationForm)
+				return form.IsOpen(), nil
+			},
+		},
+		"questions": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(applicationQuestionType))),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				form := params.Source.(ApplicationForm)
+				return form.Questions(), nil
+			},
+		},
+	},
+})
+
+var applicationAnswerType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ApplicationAnswer",
+	Fields: graphql.Fields{
+		"questionID": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"answer":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var applicationReviewType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ApplicationReview",
+	Fields: graphql.Fields{
+		"reviewer": &graphql.Field{
+			Type: publicMemberType,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return getPublicMember(params.Source.(ApplicationReview).ReviewerUUID), nil
+			},
+		},
+		"vote":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"comment":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"updatedAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+	},
+})
+
+var applicationType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Application",
+	Fields: graphql.Fields{
+		"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"status":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"decidedAt": &graphql.Field{Type: graphql.DateTime},
+		"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"form": &graphql.Field{
+			Type: graphql.NewNonNull(applicationFormType),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				var form ApplicationForm
+				database.DB.Where(&ApplicationForm{ID: params.Source.(Application).FormID}).First(&form)
+				return form, nil
+			},
+		},
+		"member": &graphql.Field{
+			Type: memberType,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				member, _ := GetMemberByUUID(params.Source.(Application).MemberUUID)
+				return member, nil
+			},
+		},
+		"answers": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(applicationAnswerType))),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				var answers []ApplicationAnswer
+				database.DB.Where(&ApplicationAnswer{ApplicationID: params.Source.(Application).ID}).Order("question_id asc").Find(&answers)
+				return answers, nil
+			},
+		},
+		"reviews": &graphql.Field{
+			Type:        graphql.NewList(graphql.NewNonNull(applicationReviewType)),
+			Description: "심사자의 의견과 투표. applications.review 권한이 필요합니다.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionReviewApplications) {
+					return nil, nil
+				}
+
+				var reviews []ApplicationReview
+				database.DB.Where(&ApplicationReview{ApplicationID: params.Source.(Application).ID}).Order("created_at asc").Find(&reviews)
+				return reviews, nil
+			},
+		},
+	},
+})
+
+var applicationQuestionInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "ApplicationQuestionInput",
+	Description: "가입 신청서 질문 InputObject",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"text":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"description": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"isRequired":  &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+	},
+})
+
+var applicationFormInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "ApplicationFormInput",
+	Description: "가입 신청서 양식 InputObject",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"title":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"description": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"opensAt":     &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		"closesAt":    &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		"questions": &graphql.InputObjectFieldConfig{
+			Type:        graphql.NewList(graphql.NewNonNull(applicationQuestionInputType)),
+			Description: "질문 목록. 제출된 신청서가 있는 양식의 질문은 바꿀 수 없습니다.",
+		},
+		"acceptedEmailTitle": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"acceptedEmailBody":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"rejectedEmailTitle": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"rejectedEmailBody":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+var applicationAnswerInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "ApplicationAnswerInput",
+	Description: "가입 신청서 답변 InputObject",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"questionID": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"answer":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+// Queries
+var ApplicationFormQuery = &graphql.Field{
+	Type:        applicationFormType,
+	Description: "현재 모집 중인 가입 신청서 양식을 조회합니다. 모집 중이 아니면 null을 반환합니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		form := getOpenApplicationForm()
+		if form == nil {
+			return nil, nil
+		}
+		return *form, nil
+	},
+}
+
+var ApplicationFormsQuery = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(applicationFormType))),
+	Description: "가입 신청서 양식 목록을 조회합니다. applications.review 권한이 필요합니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionReviewApplications) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var forms []ApplicationForm
+		database.DB.Order("opens_at desc").Find(&forms)
+		return forms, nil
+	},
+}
+
+var ApplicationsQuery = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(applicationType))),
+	Description: "가입 신청서 목록을 조회합니다. applications.review 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"formID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"status": &graphql.ArgumentConfig{Type: graphql.String},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionReviewApplications) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		query := &Application{FormID: params.Args["formID"].(int)}
+		if status, ok := params.Args["status"].(string); ok {
+			query.Status = status
+		}
+
+		var applications []Application
+		database.DB.Where(query).Order("created_at asc").Find(&applications)
+		return applications, nil
+	},
+}
+
+var MyApplicationsQuery = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(applicationType))),
+	Description: "내가 제출한 가입 신청서 목록을 조회합니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		var applications []Application
+		database.DB.Where(&Application{MemberUUID: member.UUID}).Order("created_at desc").Find(&applications)
+		return applications, nil
+	},
+}
+
+// Mutations
+var CreateApplicationFormMutation = &graphql.Field{
+	Type:        applicationFormType,
+	Description: "가입 신청서 양식을 추가합니다. applications.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"ApplicationFormInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(applicationFormInputType)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageApplications) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		input := params.Args["ApplicationFormInput"].(map[string]interface{})
+		if input["title"] == nil || input["opensAt"] == nil || input["closesAt"] == nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		var form ApplicationForm
+		return saveApplicationForm(&form, input)
+	},
+}
+
+var UpdateApplicationFormMutation = &graphql.Field{
+	Type:        applicationFormType,
+	Description: "가입 신청서 양식을 수정합니다. applications.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"formID":               &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"ApplicationFormInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(applicationFormInputType)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageApplications) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var form ApplicationForm
+		database.DB.Where(&ApplicationForm{ID: params.Args["formID"].(int)}).First(&form)
+		if form.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		input := params.Args["ApplicationFormInput"].(map[string]interface{})
+		if input["questions"] != nil {
+			// Answers refer to the questions, so they can't be changed once someone has applied.
+			var count int
+			database.DB.Model(&Application{}).Where(&Application{FormID: form.ID}).Count(&count)
+			if count > 0 {
+				return nil, fmt.Errorf("APP002")
+			}
+		}
+		return saveApplicationForm(&form, input)
+	},
+}
+
+var ReviewApplicationMutation = &graphql.Field{
+	Type:        applicationType,
+	Description: "가입 신청서에 의견을 남기고 찬반 투표를 합니다. 이미 남긴 의견은 덮어씌웁니다. applications.review 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"applicationID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"vote": &graphql.ArgumentConfig{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "APPROVE, REJECT 또는 ABSTAIN",
+		},
+		"comment": &graphql.ArgumentConfig{Type: graphql.String},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionReviewApplications) {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		var application Application
+		database.DB.Where(&Application{ID: params.Args["applicationID"].(int)}).First(&application)
+		if application.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		} else if application.Status != ApplicationStatusPending {
+			return nil, fmt.Errorf("APP001")
+		}
+
+		vote := params.Args["vote"].(string)
+		if vote != ApplicationVoteApprove && vote != ApplicationVoteReject && vote != ApplicationVoteAbstain {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		review := ApplicationReview{ApplicationID: application.ID, ReviewerUUID: member.UUID}
+		database.DB.Where(&review).First(&review)
+		review.Vote = vote
+		review.Comment, _ = params.Args["comment"].(string)
+		errs := database.DB.Save(&review).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return application, nil
+	},
+}
+
+var DecideApplicationMutation = &graphql.Field{
+	Type:        applicationType,
+	Description: "가입 신청을 승인하거나 거절하고, 지원자에게 결과 안내 메일을 발송합니다. 승인하면 회원이 활성화됩니다. applications.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"applicationID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"accept":        &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Boolean)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageApplications) {
+			return nil, fmt.Errorf("ERR401")
+		}
+		reviewer := params.Context.Value("member").(*Member)
+
+		var application Application
+		database.DB.Where(&Application{ID: params.Args["applicationID"].(int)}).First(&application)
+		if application.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		} else if application.Status != ApplicationStatusPending {
+			return nil, fmt.Errorf("APP001")
+		}
+		member, err := GetMemberByUUID(application.MemberUUID)
+		if err != nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		now := time.Now()
+		application.DecidedByUUID = reviewer.UUID
+		application.DecidedAt = &now
+		if params.Args["accept"].(bool) {
+			application.Status = ApplicationStatusAccepted
+			member.IsActivated = true
+		} else {
+			application.Status = ApplicationStatusRejected
+		}
+
+		// Only the first decision wins when several managers decide at the same time.
+		tx := database.DB.Begin()
+		result := tx.Model(&Application{}).Where("id = ? AND status = ?", application.ID, ApplicationStatusPending).Updates(map[string]interface{}{
+			"status":          application.Status,
+			"decided_by_uuid": application.DecidedByUUID,
+			"decided_at":      application.DecidedAt,
+			"updated_at":      now,
+		})
+		if result.Error != nil {
+			tx.Rollback()
+			return nil, result.Error
+		} else if result.RowsAffected == 0 {
+			tx.Rollback()
+			return nil, fmt.Errorf("APP001")
+		}
+		if errs := tx.Save(member).GetErrors(); len(errs) > 0 {
+			tx.Rollback()
+			return nil, errs[0]
+		}
+		if member.IsActivated {
+			if err := enrollInCurrentTermWithDB(tx, member.UUID); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+		if err := tx.Commit().Error; err != nil {
+			return nil, err
+		}
+
+		var form ApplicationForm
+		database.DB.Where(&ApplicationForm{ID: application.FormID}).First(&form)
+		sendApplicationResultEmail(&form, &application, member)
+		return application, nil
+	},
+}
+
+// Common functions
+func getOpenApplicationForm() *ApplicationForm {
+	now := time.Now()
+
+	var form ApplicationForm
+	database.DB.Where("opens_at <= ? and closes_at > ?", now, now).Order("opens_at desc").First(&form)
+	if form.ID == 0 {
+		return nil
+	}
+	return &form
+}
+
+func saveApplicationForm(form *ApplicationForm, input map[string]interface{}) (interface{}, error) {
+	if input["title"] != nil {
+		form.Title = input["title"].(string)
+	}
+	if input["description"] != nil {
+		form.Description = input["description"].(string)
+	}
+	if input["opensAt"] != nil {
+		form.OpensAt = input["opensAt"].(time.Time)
+	}
+	if input["closesAt"] != nil {
+		form.ClosesAt = input["closesAt"].(time.Time)
+	}
+	if input["acceptedEmailTitle"] != nil {
+		form.AcceptedEmailTitle = input["acceptedEmailTitle"].(string)
+	}
+	if input["acceptedEmailBody"] != nil {
+		form.AcceptedEmailBody = input["acceptedEmailBody"].(string)
+	}
+	if input["rejectedEmailTitle"] != nil {
+		form.RejectedEmailTitle = input["rejectedEmailTitle"].(string)
+	}
+	if input["rejectedEmailBody"] != nil {
+		form.RejectedEmailBody = input["rejectedEmailBody"].(string)
+	}
+	if strings.TrimSpace(form.Title) == "" || !form.OpensAt.Before(form.ClosesAt) {
+		return nil, fmt.Errorf("ERR400")
+	}
+
+	tx := database.DB.Begin()
+	if errs := tx.Save(form).GetErrors(); len(errs) > 0 {
+		tx.Rollback()
+		return nil, errs[0]
+	}
+	if input["questions"] != nil {
+		tx.Where(&ApplicationQuestion{FormID: form.ID}).Delete(ApplicationQuestion{})
+		for i, q := range input["questions"].([]interface{}) {
+			questionInput := q.(map[string]interface{})
+			question := ApplicationQuestion{FormID: form.ID, Position: i, Text: questionInput["text"].(string)}
+			question.Description, _ = questionInput["description"].(string)
+			question.IsRequired, _ = questionInput["isRequired"].(bool)
+			if errs := tx.Create(&question).GetErrors(); len(errs) > 0 {
+				tx.Rollback()
+				return nil, errs[0]
+			}
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return *form, nil
+}
+
+// createApplicationWithDB는 회원가입과 함께 제출된 답변으로 가입 신청서를 만듭니다.
+// 모든 필수 질문에 답해야 하며, 양식에 없는 질문에 대한 답변은 받지 않습니다.
+func createApplicationWithDB(db *gorm.DB, form *ApplicationForm, member *Member, answerInputs []interface{}) error {
+	answers := make(map[int]string)
+	for _, a := range answerInputs {
+		answerInput := a.(map[string]interface{})
+		answers[answerInput["questionID"].(int)] = strings.TrimSpace(answerInput["answer"].(string))
+	}
+
+	questions := form.Questions()
+	for _, question := range questions {
+		if question.IsRequired && answers[question.ID] == "" {
+			return fmt.Errorf("APP000")
+		}
+	}
+
+	application := Application{FormID: form.ID, MemberUUID: member.UUID, Status: ApplicationStatusPending}
+	if err := db.Create(&application).Error; err != nil {
+		return err
+	}
+	for _, question := range questions {
+		text, ok := answers[question.ID]
+		if !ok {
+			continue
+		}
+		delete(answers, question.ID)
+
+		answer := ApplicationAnswer{ApplicationID: application.ID, QuestionID: question.ID, Answer: text}
+		if err := db.Create(&answer).Error; err != nil {
+			return err
+		}
+	}
+	if len(answers) > 0 {
+		return fmt.Errorf("APP000")
+	}
+	return nil
+}
+
+func sendApplicationResultEmail(form *ApplicationForm, application *Application, member *Member) {
+	title, body := applicationRejectedEmailTitle, applicationRejectedEmailBody
+	if form.RejectedEmailTitle != "" {
+		title = form.RejectedEmailTitle
+	}
+	if form.RejectedEmailBody != "" {
+		body = form.RejectedEmailBody
+	}
+	if application.Status == ApplicationStatusAccepted {
+		title, body = applicationAcceptedEmailTitle, applicationAcceptedEmailBody
+		if form.AcceptedEmailTitle != "" {
+			title = form.AcceptedEmailTitle
+		}
+		if form.AcceptedEmailBody != "" {
+			body = form.AcceptedEmailBody
+		}
+	}
+
+	replacer := strings.NewReplacer("{name}", member.Name, "{loginID}", member.LoginID, "{formTitle}", form.Title)
+	mail := email.Email{
+		Title: replacer.Replace(title),
+		Body:  replacer.Replace(body),
+		To:    member.Email,
+	}
+	go func() { mail.Send() }()
+}
@@ -13,8 +13,14 @@ type Board struct {
 	ID              int
 	Name            string `gorm:"type:varchar(40);UNIQUE_INDEX"`
 	URLPath         string `gorm:"type:varchar(40);UNIQUE_INDEX"`
-	ReadPermission  string `gorm:"type:varchar(10)"`
-	WritePermission string `gorm:"type:varchar(10)"`
+	ReadPermission  string `gorm:"type:varchar(40)"`
+	WritePermission string `gorm:"type:varchar(40)"`
+
+	// 메뉴에 표시할 분류와 순서. 보관된 게시판에는 글이나 댓글을 쓸 수 없습니다.
+	CategoryID *int `gorm:"INDEX"`
+	Position   int  `gorm:"NOT NULL;default:0"`
+	IsHidden   bool `gorm:"default:false"`
+	IsArchived bool `gorm:"default:false"`
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -23,11 +29,20 @@ type Board struct {
 var boardType = graphql.NewObject(graphql.ObjectConfig{
 	Name: "Board",
 	Fields: graphql.Fields{
-		"id":              &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
-		"name":            &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
-		"urlPath":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
-		"readPermission":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
-		"writePermission": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"id":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"name":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"urlPath": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"readPermission": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "PUBLIC(모든 사용자), MEMBER(로그인한 회원), GROUPS(권한을 받은 그룹의 회원만) 또는 읽기에 필요한 권한 이름",
+		},
+		"writePermission": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "PUBLIC(모든 사용자), MEMBER(로그인한 회원), GROUPS(권한을 받은 그룹의 회원만) 또는 쓰기에 필요한 권한 이름",
+		},
+		"position":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "같은 분류 안에서의 표시 순서 (오름차순)"},
+		"isHidden":   &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"isArchived": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
 		"postPage": &graphql.Field{
 			Type: graphql.NewList(graphql.NewNonNull(postType)),
 			Args: graphql.FieldConfigArgument{
@@ -36,7 +51,46 @@ var boardType = graphql.NewObject(graphql.ObjectConfig{
 				"count":  &graphql.ArgumentConfig{Type: graphql.Int},
 			},
 			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-				return getPostPage(params.Source.(Board).ID, getPaginationFromGraphQLParams(&params)), nil
+				board := params.Source.(Board)
+				member := requestMember(params.Context, scopePostsRead)
+				if !member.canReadBoard(&board) {
+					return nil, fmt.Errorf("ERR403")
+				}
+				return getPostPage(board.ID, member, getPaginationFromGraphQLParams(&params)), nil
+			},
+		},
+		"pinnedPosts": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postType))),
+			Description: "게시판 상단에 고정된 게시물",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				board := params.Source.(Board)
+				member := requestMember(params.Context, scopePostsRead)
+				if !member.canReadBoard(&board) {
+					return []Post{}, nil
+				}
+
+				query := database.DB.Where(&Post{BoardID: board.ID, IsPinned: true})
+				if !member.canModerateBoard(board.ID) {
+					query = visibleToAuthor(query, member)
+				}
+				posts := []Post{}
+				query.Order("id desc").Find(&posts)
+				return posts, nil
+			},
+		},
+		"moderators": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(publicMemberType))),
+			Description: "게시판 운영자 목록",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				board := params.Source.(Board)
+				members := []Member{}
+				if !requestMember(params.Context, scopePostsRead).canReadBoard(&board) {
+					return members, nil
+				}
+
+				database.DB.Joins("JOIN board_moderators ON board_moderators.member_uuid = members.uuid").
+					Where("board_moderators.board_id = ?", board.ID).Order("board_moderators.created_at asc").Find(&members)
+				return members, nil
 			},
 		},
 		"isSubscribed": &graphql.Field{
@@ -60,9 +114,16 @@ var boardInputType = graphql.NewInputObject(graphql.InputObjectConfig{
 	Description: "게시판 추가/수정 InputObject",
 	Fields: graphql.InputObjectConfigFieldMap{
 		"name":            &graphql.InputObjectFieldConfig{Type: graphql.String},
-		"urlPath":         &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"urlPath":         &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "게시판의 URL 경로. 비워둘 수 없습니다."},
 		"readPermission":  &graphql.InputObjectFieldConfig{Type: graphql.String},
 		"writePermission": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"categoryID": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Int,
+			Description: "게시판 분류의 ID. 0이면 분류에서 뺍니다.",
+		},
+		"position":   &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		"isHidden":   &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+		"isArchived": &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
 	},
 })
 
@@ -82,46 +143,64 @@ var boardSubscriptionType = graphql.NewObject(graphql.ObjectConfig{
 // Queries
 var BoardQuery = &graphql.Field{
 	Type:        boardType,
-	Description: "게시판을 조회합니다.",
+	Description: "게시판을 조회합니다. boardID 또는 urlPath 중 하나가 필요합니다. 해당 게시판에 읽기 권한이 있어야 하며, 숨긴 게시판은 boards.manage 권한이 필요합니다.",
 	Args: graphql.FieldConfigArgument{
-		"boardID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"boardID": &graphql.ArgumentConfig{Type: graphql.Int},
+		"urlPath": &graphql.ArgumentConfig{Type: graphql.String, Description: "게시판의 URL 경로"},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
 		var board Board
-		database.DB.Where(&Board{ID: params.Args["boardID"].(int)}).First(&board)
+		if boardID, ok := params.Args["boardID"].(int); ok {
+			database.DB.Where(&Board{ID: boardID}).First(&board)
+		} else if urlPath, _ := params.Args["urlPath"].(string); urlPath != "" {
+			database.DB.Where("url_path = ?", urlPath).First(&board)
+		}
+		if board.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		member := requestMember(params.Context, scopePostsRead)
+		if !member.canReadBoard(&board) || (board.IsHidden && !member.Can(PermissionManageBoards)) {
+			return nil, fmt.Errorf("ERR403")
+		}
 		return board, nil
 	},
 }
 
 var BoardsQuery = &graphql.Field{
 	Type:        graphql.NewList(boardType),
-	Description: "게시판 목록을 조회합니다",
+	Description: "게시판 목록을 표시 순서대로 조회합니다. 숨긴 게시판은 boards.manage 권한이 있는 회원에게만 보입니다.",
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		var boards []Board
-		database.DB.Order("id asc").Find(&boards)
-		return boards, nil
+		return getVisibleBoards(requestMember(params.Context, scopePostsRead), nil), nil
 	},
 }
 
 // Mutations
 var CreateBoardMutation = &graphql.Field{
 	Type:        boardType,
-	Description: "게시판을 추가합니다. 관리자 권한이 필요합니다.",
+	Description: "게시판을 추가합니다. boards.manage 권한이 필요합니다.",
 	Args: graphql.FieldConfigArgument{
 		"BoardInput": &graphql.ArgumentConfig{Type: boardInputType},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if member := params.Context.Value("member"); member == nil || !member.(*Member).IsAdmin {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageBoards) {
 			return nil, fmt.Errorf("ERR401")
 		}
 
 		boardInput, _ := params.Args["BoardInput"].(map[string]interface{})
+		urlPath, _ := boardInput["urlPath"].(string)
 		board := Board{
 			Name:            boardInput["name"].(string),
-			URLPath:         boardInput["urlPath"].(string),
+			URLPath:         urlPath,
 			ReadPermission:  boardInput["readPermission"].(string),
 			WritePermission: boardInput["writePermission"].(string),
 		}
+		if board.URLPath == "" || !isBoardAccess(board.ReadPermission) || !isBoardAccess(board.WritePermission) {
+			return nil, fmt.Errorf("ERR400")
+		}
+		if err := setBoardDisplayInput(&board, boardInput); err != nil {
+			return nil, err
+		}
 		errs := database.DB.Save(&board).GetErrors()
 		if len(errs) > 0 {
 			return nil, errs[0]
@@ -133,13 +212,13 @@ var CreateBoardMutation = &graphql.Field{
 
 var UpdateBoardMutation = &graphql.Field{
 	Type:        boardType,
-	Description: "게시판을 수정합니다. 관리자 권한이 필요합니다.",
+	Description: "게시판을 수정합니다. boards.manage 권한이 필요합니다.",
 	Args: graphql.FieldConfigArgument{
 		"boardID":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
 		"BoardInput": &graphql.ArgumentConfig{Type: boardInputType},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if member := params.Context.Value("member"); member == nil || !member.(*Member).IsAdmin {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageBoards) {
 			return nil, fmt.Errorf("ERR401")
 		}
 
@@ -163,6 +242,12 @@ var UpdateBoardMutation = &graphql.Field{
 		if boardInput["writePermission"] != nil {
 			board.WritePermission = boardInput["writePermission"].(string)
 		}
+		if board.URLPath == "" || !isBoardAccess(board.ReadPermission) || !isBoardAccess(board.WritePermission) {
+			return nil, fmt.Errorf("ERR400")
+		}
+		if err := setBoardDisplayInput(&board, boardInput); err != nil {
+			return nil, err
+		}
 
 		errs := database.DB.Save(&board).GetErrors()
 		if len(errs) > 0 {
@@ -175,12 +260,12 @@ var UpdateBoardMutation = &graphql.Field{
 
 var DeleteBoardMutation = &graphql.Field{
 	Type:        boardType,
-	Description: "게시판을 삭제합니다. 관리자 권한이 필요합니다.",
+	Description: "게시판을 삭제합니다. boards.manage 권한이 필요합니다.",
 	Args: graphql.FieldConfigArgument{
 		"boardID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if member := params.Context.Value("member"); member == nil || !member.(*Member).IsAdmin {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageBoards) {
 			return nil, fmt.Errorf("ERR401")
 		}
 
@@ -245,3 +330,32 @@ var UnsubscribeBoardMutation = &graphql.Field{
 		return subscription, nil
 	},
 }
+
+// Common functions
+
+// setBoardDisplayInput은 입력된 게시판의 분류, 표시 순서, 숨김 및 보관 여부를 반영합니다.
+func setBoardDisplayInput(board *Board, boardInput map[string]interface{}) error {
+	if boardInput["categoryID"] != nil {
+		categoryID := boardInput["categoryID"].(int)
+		if categoryID == 0 {
+			board.CategoryID = nil
+		} else {
+			var category BoardCategory
+			database.DB.Where(&BoardCategory{ID: categoryID}).First(&category)
+			if category.ID == 0 {
+				return fmt.Errorf("ERR400")
+			}
+			board.CategoryID = &categoryID
+		}
+	}
+	if boardInput["position"] != nil {
+		board.Position = boardInput["position"].(int)
+	}
+	if boardInput["isHidden"] != nil {
+		board.IsHidden = boardInput["isHidden"].(bool)
+	}
+	if boardInput["isArchived"] != nil {
+		board.IsArchived = boardInput["isArchived"].(bool)
+	}
+	return nil
+}
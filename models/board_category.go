@@ -0,0 +1,321 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+
+	"nagase/components/database"
+)
+
+// 게시판 분류를 중첩할 수 있는 최대 깊이
+const boardCategoryMaxDepth = 3
+
+// BoardCategory는 사이트 메뉴를 구성하는 게시판 분류입니다. 분류 안에 다른 분류나 게시판을 둘 수 있습니다.
+// 숨긴 분류는 boards.manage 권한이 없는 회원의 목록에 보이지 않으며, 보관된 분류의 게시판에는 글을 쓸 수 없습니다.
+type BoardCategory struct {
+	ID int
+
+	Name       string `gorm:"type:varchar(40)"`
+	ParentID   *int   `gorm:"INDEX"`
+	Position   int    `gorm:"NOT NULL;default:0"`
+	IsHidden   bool   `gorm:"default:false"`
+	IsArchived bool   `gorm:"default:false"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+var boardCategoryType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "BoardCategory",
+	Description: "게시판 분류",
+	Fields: graphql.Fields{
+		// To ignore circular dependency, `parent`, `children` fields have initialized lazily on init() method.
+		"id":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"name":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"position":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "같은 분류 안에서의 표시 순서 (오름차순)"},
+		"isHidden":   &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"isArchived": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"boards": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(boardType))),
+			Description: "분류에 속한 게시판. 숨긴 게시판은 boards.manage 권한이 있는 회원에게만 보입니다.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				categoryID := params.Source.(BoardCategory).ID
+				return getVisibleBoards(requestMember(params.Context, scopePostsRead), &categoryID), nil
+			},
+		},
+	},
+})
+
+var boardCategoryInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "BoardCategoryInput",
+	Description: "게시판 분류 추가/수정 InputObject",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"parentID": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Int,
+			Description: "상위 분류의 ID. 0이면 최상위 분류로 옮깁니다.",
+		},
+		"position":   &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		"isHidden":   &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+		"isArchived": &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+	},
+})
+
+// Queries
+var BoardCategoriesQuery = &graphql.Field{
+	Type:        graphql.NewList(graphql.NewNonNull(boardCategoryType)),
+	Description: "최상위 게시판 분류 목록을 표시 순서대로 조회합니다. 하위 분류는 children 필드로 조회합니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		return getVisibleBoardCategories(requestMember(params.Context, scopePostsRead), nil), nil
+	},
+}
+
+// Mutations
+var CreateBoardCategoryMutation = &graphql.Field{
+	Type:        boardCategoryType,
+	Description: "게시판 분류를 추가합니다. boards.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"BoardCategoryInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(boardCategoryInputType)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageBoards) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var category BoardCategory
+		if err := setBoardCategoryInput(&category, params.Args["BoardCategoryInput"].(map[string]interface{})); err != nil {
+			return nil, err
+		}
+		errs := database.DB.Create(&category).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return category, nil
+	},
+}
+
+var UpdateBoardCategoryMutation = &graphql.Field{
+	Type:        boardCategoryType,
+	Description: "게시판 분류를 수정합니다. boards.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"categoryID":         &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"BoardCategoryInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(boardCategoryInputType)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageBoards) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var category BoardCategory
+		database.DB.Where(&BoardCategory{ID: params.Args["categoryID"].(int)}).First(&category)
+		if category.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		if err := setBoardCategoryInput(&category, params.Args["BoardCategoryInput"].(map[string]interface{})); err != nil {
+			return nil, err
+		}
+		errs := database.DB.Save(&category).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return category, nil
+	},
+}
+
+var DeleteBoardCategoryMutation = &graphql.Field{
+	Type:        boardCategoryType,
+	Description: "게시판 분류를 삭제합니다. 하위 분류나 게시판이 있는 분류는 삭제할 수 없습니다. boards.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"categoryID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageBoards) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var category BoardCategory
+		database.DB.Where(&BoardCategory{ID: params.Args["categoryID"].(int)}).First(&category)
+		if category.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		var childCount, boardCount int
+		database.DB.Model(&BoardCategory{}).Where(&BoardCategory{ParentID: &category.ID}).Count(&childCount)
+		database.DB.Model(&Board{}).Where(&Board{CategoryID: &category.ID}).Count(&boardCount)
+		if childCount > 0 || boardCount > 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		errs := database.DB.Delete(&category).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return category, nil
+	},
+}
+
+// Common functions
+
+// setBoardCategoryInput은 입력된 값을 분류에 반영합니다. 상위 분류는 존재해야 하며, 자기 자신의 하위 분류가 될 수 없습니다.
+func setBoardCategoryInput(category *BoardCategory, input map[string]interface{}) error {
+	if input["name"] != nil {
+		category.Name = strings.TrimSpace(input["name"].(string))
+	}
+	if category.Name == "" {
+		return fmt.Errorf("ERR400")
+	}
+	if input["position"] != nil {
+		category.Position = input["position"].(int)
+	}
+	if input["isHidden"] != nil {
+		category.IsHidden = input["isHidden"].(bool)
+	}
+	if input["isArchived"] != nil {
+		category.IsArchived = input["isArchived"].(bool)
+	}
+
+	if input["parentID"] != nil {
+		parentID := input["parentID"].(int)
+		if parentID == 0 {
+			category.ParentID = nil
+			return nil
+		}
+
+		// Walk up from the new parent to make sure the tree stays acyclic, counting the levels above the category.
+		levels := 0
+		for id := &parentID; id != nil; levels++ {
+			var parent BoardCategory
+			database.DB.Where(&BoardCategory{ID: *id}).First(&parent)
+			if parent.ID == 0 || (category.ID != 0 && parent.ID == category.ID) || levels >= boardCategoryMaxDepth {
+				return fmt.Errorf("ERR400")
+			}
+			id = parent.ParentID
+		}
+		// The subtree moves along with the category, so it has to fit below the new parent as well.
+		if levels+category.subtreeHeight() > boardCategoryMaxDepth {
+			return fmt.Errorf("ERR400")
+		}
+		category.ParentID = &parentID
+	}
+	return nil
+}
+
+// subtreeHeight는 분류와 그 하위 분류가 차지하는 단계 수를 반환합니다. 하위 분류가 없으면 1입니다.
+func (category *BoardCategory) subtreeHeight() int {
+	if category.ID == 0 {
+		return 1
+	}
+
+	height := 1
+	ids := []int{category.ID}
+	for height <= boardCategoryMaxDepth {
+		var children []BoardCategory
+		database.DB.Where("parent_id IN (?)", ids).Find(&children)
+		if len(children) == 0 {
+			break
+		}
+		height++
+		ids = []int{}
+		for _, child := range children {
+			ids = append(ids, child.ID)
+		}
+	}
+	return height
+}
+
+// getVisibleBoardCategories는 상위 분류가 parentID인 분류를 표시 순서대로 가져옵니다. 숨긴 분류는 boards.manage 권한이 있는 회원에게만 보입니다.
+func getVisibleBoardCategories(member *Member, parentID *int) []BoardCategory {
+	query := database.DB.Order("position asc, id asc")
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+	if !member.Can(PermissionManageBoards) {
+		query = query.Where("is_hidden = ?", false)
+	}
+
+	categories := []BoardCategory{}
+	query.Find(&categories)
+	return categories
+}
+
+// getVisibleBoards는 분류가 categoryID인 게시판을 표시 순서대로 가져옵니다. categoryID가 nil이면 모든 게시판을 가져옵니다.
+// 숨긴 게시판은 boards.manage 권한이 있는 회원에게만 보입니다.
+func getVisibleBoards(member *Member, categoryID *int) []Board {
+	query := database.DB.Order("position asc, id asc")
+	if categoryID != nil {
+		query = query.Where("category_id = ?", *categoryID)
+	}
+	if !member.Can(PermissionManageBoards) {
+		query = query.Where("is_hidden = ?", false)
+	}
+
+	boards := []Board{}
+	query.Find(&boards)
+	return boards
+}
+
+// isArchived는 게시판이나 게시판이 속한 분류가 보관되었는지 확인합니다. 보관된 게시판에는 글이나 댓글을 쓸 수 없습니다.
+func (board *Board) isArchived() bool {
+	if board.IsArchived {
+		return true
+	}
+	categoryID := board.CategoryID
+	for depth := 0; categoryID != nil && depth < boardCategoryMaxDepth; depth++ {
+		var category BoardCategory
+		database.DB.Where(&BoardCategory{ID: *categoryID}).First(&category)
+		if category.ID == 0 {
+			return false
+		} else if category.IsArchived {
+			return true
+		}
+		categoryID = category.ParentID
+	}
+	return false
+}
+
+func init() {
+	boardCategoryType.AddFieldConfig("parent", &graphql.Field{
+		Type: boardCategoryType,
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			parentID := params.Source.(BoardCategory).ParentID
+			if parentID == nil {
+				return nil, nil
+			}
+			var parent BoardCategory
+			database.DB.Where(&BoardCategory{ID: *parentID}).First(&parent)
+			if parent.ID == 0 {
+				return nil, nil
+			}
+			return parent, nil
+		},
+	})
+	boardCategoryType.AddFieldConfig("children", &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(boardCategoryType))),
+		Description: "하위 분류. 숨긴 분류는 boards.manage 권한이 있는 회원에게만 보입니다.",
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			categoryID := params.Source.(BoardCategory).ID
+			return getVisibleBoardCategories(requestMember(params.Context, scopePostsRead), &categoryID), nil
+		},
+	})
+	boardType.AddFieldConfig("category", &graphql.Field{
+		Type: boardCategoryType,
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			categoryID := params.Source.(Board).CategoryID
+			if categoryID == nil {
+				return nil, nil
+			}
+			var category BoardCategory
+			database.DB.Where(&BoardCategory{ID: *categoryID}).First(&category)
+			if category.ID == 0 {
+				return nil, nil
+			}
+			return category, nil
+		},
+	})
+}
@@ -0,0 +1,228 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/graphql-go/graphql"
+
+	"nagase/components/database"
+)
+
+// BoardModerator는 게시판의 운영자입니다. 운영자는 맡은 게시판에서만 게시물과 댓글을 삭제, 숨김, 이동, 고정할 수 있습니다.
+// posts.moderate 권한이 있는 회원은 모든 게시판의 운영자로 취급합니다.
+type BoardModerator struct {
+	BoardID    int    `gorm:"PRIMARY_KEY;auto_increment:false"`
+	MemberUUID string `gorm:"type:varchar(40);PRIMARY_KEY"`
+
+	CreatedAt time.Time
+}
+
+// canModerateBoard는 회원이 게시판의 게시물과 댓글을 관리할 수 있는지 확인합니다. 회원은 nil일 수 있습니다.
+func (member *Member) canModerateBoard(boardID int) bool {
+	if member == nil {
+		return false
+	} else if member.Can(PermissionModeratePosts) {
+		return true
+	} else if boardID == 0 {
+		// Records whose board no longer exists are only managed by posts.moderate holders.
+		return false
+	}
+
+	var moderator BoardModerator
+	database.DB.Where("board_id = ? AND member_uuid = ?", boardID, member.UUID).First(&moderator)
+	return moderator.BoardID != 0
+}
+
+// Mutations
+var AssignBoardModeratorMutation = &graphql.Field{
+	Type:        boardType,
+	Description: "회원을 게시판의 운영자로 지정합니다. boards.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"boardID":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"memberUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageBoards) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var board Board
+		database.DB.Where(&Board{ID: params.Args["boardID"].(int)}).First(&board)
+		if board.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+		member, err := GetMemberByUUID(params.Args["memberUUID"].(string))
+		if err != nil || !member.IsActivated {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		errs := database.DB.Save(&BoardModerator{BoardID: board.ID, MemberUUID: member.UUID}).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return board, nil
+	},
+}
+
+var UnassignBoardModeratorMutation = &graphql.Field{
+	Type:        boardType,
+	Description: "게시판의 운영자 지정을 해제합니다. boards.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"boardID":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"memberUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageBoards) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var board Board
+		database.DB.Where(&Board{ID: params.Args["boardID"].(int)}).First(&board)
+		if board.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		errs := database.DB.Where(&BoardModerator{BoardID: board.ID, MemberUUID: params.Args["memberUUID"].(string)}).Delete(BoardModerator{}).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return board, nil
+	},
+}
+
+var HidePostMutation = &graphql.Field{
+	Type:        postType,
+	Description: "게시물을 숨기거나 다시 보이게 합니다. 숨긴 게시물은 작성자와 게시판 운영자만 볼 수 있습니다. 게시판 운영자만 할 수 있습니다.",
+	Args: graphql.FieldConfigArgument{
+		"postID":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"isHidden": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Boolean)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		post, err := getModeratedPost(params)
+		if err != nil {
+			return nil, err
+		}
+
+		post.IsHidden = params.Args["isHidden"].(bool)
+		errs := database.DB.Save(post).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return *post, nil
+	},
+}
+
+var PinPostMutation = &graphql.Field{
+	Type:        postType,
+	Description: "게시물을 게시판 상단에 고정하거나 고정을 해제합니다. 게시판 운영자만 할 수 있습니다.",
+	Args: graphql.FieldConfigArgument{
+		"postID":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"isPinned": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Boolean)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		post, err := getModeratedPost(params)
+		if err != nil {
+			return nil, err
+		}
+
+		post.IsPinned = params.Args["isPinned"].(bool)
+		errs := database.DB.Save(post).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return *post, nil
+	},
+}
+
+var MovePostMutation = &graphql.Field{
+	Type:        postType,
+	Description: "게시물을 다른 게시판으로 옮깁니다. 두 게시판 모두의 운영자만 할 수 있습니다.",
+	Args: graphql.FieldConfigArgument{
+		"postID":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"boardID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int), Description: "옮길 게시판의 ID"},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		post, err := getModeratedPost(params)
+		if err != nil {
+			return nil, err
+		}
+
+		var board Board
+		database.DB.Where(&Board{ID: params.Args["boardID"].(int)}).First(&board)
+		if board.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		} else if !requestMember(params.Context, scopePostsWrite).canModerateBoard(board.ID) {
+			return nil, fmt.Errorf("ERR403")
+		}
+
+		// Pins belong to the board the post was pinned on.
+		post.BoardID = board.ID
+		post.IsPinned = false
+		errs := database.DB.Save(post).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		// Old slugs keep pointing at the post from the previous board.
+		if err := updatePostSlugWithDB(database.DB, post); err != nil {
+			return nil, err
+		}
+		return *post, nil
+	},
+}
+
+var HideCommentMutation = &graphql.Field{
+	Type:        commentType,
+	Description: "댓글을 숨기거나 다시 보이게 합니다. 숨긴 댓글은 작성자와 게시판 운영자만 볼 수 있습니다. 게시판 운영자만 할 수 있습니다.",
+	Args: graphql.FieldConfigArgument{
+		"commentID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"isHidden":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Boolean)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		member := requestMember(params.Context, scopePostsWrite)
+		if member == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var comment Comment
+		database.DB.Where(&Comment{ID: params.Args["commentID"].(int)}).First(&comment)
+		if comment.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		} else if !member.canModerateBoard(getCommentBoardID(&comment)) {
+			return nil, fmt.Errorf("ERR403")
+		}
+
+		comment.IsHidden = params.Args["isHidden"].(bool)
+		errs := database.DB.Save(&comment).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return comment, nil
+	},
+}
+
+// Common functions
+
+// getModeratedPost는 postID 인자의 게시물을 가져오고, 요청한 회원이 게시물이 있는 게시판의 운영자인지 확인합니다.
+func getModeratedPost(params graphql.ResolveParams) (*Post, error) {
+	member := requestMember(params.Context, scopePostsWrite)
+	if member == nil {
+		return nil, fmt.Errorf("ERR401")
+	}
+
+	var post Post
+	database.DB.Where(&Post{ID: params.Args["postID"].(int)}).First(&post)
+	if post.ID == 0 {
+		return nil, fmt.Errorf("ERR400")
+	} else if !member.canModerateBoard(post.BoardID) {
+		return nil, fmt.Errorf("ERR403")
+	}
+	return &post, nil
+}
+
+// getCommentBoardID는 댓글이 달린 게시물의 게시판 ID를 반환합니다.
+func getCommentBoardID(comment *Comment) int {
+	var post Post
+	database.DB.Where(&Post{ID: comment.PostID}).First(&post)
+	return post.BoardID
+}
@@ -17,6 +17,7 @@ type Comment struct {
 	PostID     int    `gorm:"INDEX"`
 	AuthorUUID string `gorm:"type:varchar(40)"`
 	Body       string
+	IsHidden   bool `gorm:"default:false"`
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -25,14 +26,16 @@ type Comment struct {
 var commentType = graphql.NewObject(graphql.ObjectConfig{
 	Name: "Comment",
 	Fields: graphql.Fields{
-		"id": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"id":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"postID": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
 		"author": &graphql.Field{
-			Type: graphql.NewNonNull(memberType),
+			Type: graphql.NewNonNull(publicMemberType),
 			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-				return GetMemberByUUID(params.Source.(Comment).AuthorUUID)
+				return getPublicMember(params.Source.(Comment).AuthorUUID), nil
 			},
 		},
 		"body":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"isHidden":  &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
 		"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
 	},
 })
@@ -46,13 +49,13 @@ var CreateCommentMutation = &graphql.Field{
 		"body":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if params.Context.Value("member") == nil {
+		member := requestMember(params.Context, scopePostsWrite)
+		if member == nil {
 			return nil, fmt.Errorf("ERR401")
 		}
-		member := params.Context.Value("member").(*Member)
 
 		// 권한을 확인합니다.
-		// 해당 게시판에 읽기 권한이 있는 모든 사용자는 댓글을 달 수 있습니다.
+		// 해당 게시판에 읽기 권한이 있거나 댓글 권한을 받은 그룹의 회원은 댓글을 달 수 있습니다. 보관된 게시판이나 볼 수 없는 숨긴 게시물에는 댓글을 달 수 없습니다.
 		postID, _ := params.Args["postID"].(int)
 		post := new(Post)
 		database.DB.Where(&Post{ID: postID}).First(&post)
@@ -64,7 +67,7 @@ var CreateCommentMutation = &graphql.Field{
 		database.DB.Where(&Board{ID: post.BoardID}).First(&board)
 		if board.ID == 0 {
 			return nil, fmt.Errorf("ERR400")
-		} else if board.ReadPermission == "ADMIN" && !member.IsAdmin {
+		} else if !member.canReadPost(post) || !member.canCommentBoard(board) {
 			return nil, fmt.Errorf("ERR403")
 		}
 
@@ -99,7 +102,7 @@ var CreateCommentMutation = &graphql.Field{
 
 var DeleteCommentMutation = &graphql.Field{
 	Type:        commentType,
-	Description: "댓글을 삭제합니다. 작성자 본인 또는 관리자만 댓글을 삭제할 수 있습니다.",
+	Description: "댓글을 삭제합니다. 작성자 본인 또는 게시판 운영자(posts.moderate 권한 포함)만 댓글을 삭제할 수 있습니다.",
 	Args: graphql.FieldConfigArgument{
 		"commentID": &graphql.ArgumentConfig{
 			Type:        graphql.NewNonNull(graphql.Int),
@@ -107,10 +110,10 @@ var DeleteCommentMutation = &graphql.Field{
 		},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if params.Context.Value("member") == nil {
+		member := requestMember(params.Context, scopePostsWrite)
+		if member == nil {
 			return nil, fmt.Errorf("ERR401")
 		}
-		member := params.Context.Value("member").(*Member)
 
 		// Get comment and check permission.
 		var comment Comment
@@ -118,7 +121,7 @@ var DeleteCommentMutation = &graphql.Field{
 		database.DB.Where(&Comment{ID: commentID}).First(&comment)
 		if comment.ID == 0 {
 			return nil, fmt.Errorf("ERR400")
-		} else if comment.AuthorUUID != member.UUID && !member.IsAdmin {
+		} else if comment.AuthorUUID != member.UUID && !member.canModerateBoard(getCommentBoardID(&comment)) {
 			return nil, fmt.Errorf("ERR403")
 		}
 
@@ -0,0 +1,356 @@
+package models
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+
+	"nagase/components/auth"
+	"nagase/components/database"
+	"nagase/components/email"
+)
+
+// 개인 정보 내보내기 상태
+const (
+	DataExportStatusPending = "PENDING"
+	DataExportStatusReady   = "READY"
+	DataExportStatusFailed  = "FAILED"
+)
+
+const (
+	dataExportLifetime      = 7 * 24 * time.Hour
+	dataExportCleanInterval = time.Hour
+
+	// 이 시간이 지나도록 만들어지지 않은 내보내기는 서버가 재시작되는 등의 이유로 중단된 것으로 보고 실패 처리합니다.
+	dataExportBuildTimeout = 30 * time.Minute
+)
+
+var dataExportBaseDir string
+var dataExportBaseURL string
+
+// DataExport는 회원이 요청한 개인 정보 내보내기입니다.
+// 만들어진 ZIP 파일은 다운로드 링크의 토큰으로만 받을 수 있으며, 토큰은 해시만 저장합니다.
+type DataExport struct {
+	ID int
+
+	MemberUUID string `gorm:"type:varchar(40);INDEX"`
+	Status     string `gorm:"type:varchar(20)"`
+	TokenHash  string `gorm:"type:varchar(64);INDEX" json:"-"`
+	ExpiresAt  *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (export *DataExport) filePath() string {
+	return fmt.Sprintf("%s/%d.zip", dataExportBaseDir, export.ID)
+}
+
+var dataExportEmailTitle = "개인 정보 내보내기 완료 안내"
+var dataExportEmailBody = `
+안녕하세요,
+PoolC 홈페이지 개인 정보 내보내기 안내 메일입니다.
+
+요청하신 %s 계정의 개인 정보 파일이 준비되었습니다. 아래 링크에서 받을 수 있습니다.
+<a href="%s">%s</a>
+링크는 7일 동안 유효합니다.
+
+본인이 요청하지 않은 경우, 비밀번호를 바꿔주세요.
+감사합니다.
+`
+
+var dataExportType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "DataExport",
+	Description: "개인 정보 내보내기. 다운로드 링크는 완료되었을 때 메일로만 안내합니다.",
+	Fields: graphql.Fields{
+		"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"status":    &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "PENDING, READY 또는 FAILED"},
+		"expiresAt": &graphql.Field{Type: graphql.DateTime, Description: "다운로드 링크의 만료 시각"},
+		"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+	},
+})
+
+// Queries
+var MyDataExportsQuery = &graphql.Field{
+	Type:        graphql.NewList(graphql.NewNonNull(dataExportType)),
+	Description: "요청한 개인 정보 내보내기 목록을 조회합니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		var exports []DataExport
+		database.DB.Where(&DataExport{MemberUUID: member.UUID}).Order("id desc").Find(&exports)
+		return exports, nil
+	},
+}
+
+// Mutations
+var RequestDataExportMutation = &graphql.Field{
+	Type:        dataExportType,
+	Description: "개인 정보 내보내기를 요청합니다. 프로필, 게시물, 댓글, 투표, 구독 정보와 업로드한 파일을 ZIP 파일로 묶어, 완료되면 다운로드 링크를 메일로 보냅니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		// Only one export can be built at a time.
+		failStaleDataExports()
+		var pending DataExport
+		database.DB.Where(&DataExport{MemberUUID: member.UUID, Status: DataExportStatusPending}).First(&pending)
+		if pending.ID != 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		export := DataExport{MemberUUID: member.UUID, Status: DataExportStatusPending}
+		errs := database.DB.Create(&export).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+
+		go buildDataExport(export)
+		return export, nil
+	},
+}
+
+// Common functions
+
+// GetDataExportFile은 다운로드 링크의 토큰에 해당하는 개인 정보 파일을 반환합니다.
+func GetDataExportFile(token string) (*os.File, error) {
+	var export DataExport
+	database.DB.Where(&DataExport{TokenHash: auth.HashRefreshToken(token), Status: DataExportStatusReady}).First(&export)
+	if export.ID == 0 || export.ExpiresAt == nil || export.ExpiresAt.Before(time.Now()) {
+		return nil, fmt.Errorf("invalid data export token")
+	}
+	return os.Open(export.filePath())
+}
+
+// buildDataExport는 개인 정보 파일을 만들고, 다운로드 링크를 메일로 보냅니다.
+func buildDataExport(export DataExport) {
+	member, err := GetMemberByUUID(export.MemberUUID)
+	if err == nil {
+		err = writeDataExport(&export, member)
+	}
+
+	var token string
+	if err == nil {
+		token, err = auth.GenerateRefreshToken()
+	}
+	if err != nil {
+		fmt.Println("Failed to build data export", export.ID, err)
+		os.Remove(export.filePath())
+		database.DB.Model(&export).Update("status", DataExportStatusFailed)
+		return
+	}
+
+	expiresAt := time.Now().Add(dataExportLifetime)
+	database.DB.Model(&export).Updates(DataExport{
+		Status:    DataExportStatusReady,
+		TokenHash: auth.HashRefreshToken(token),
+		ExpiresAt: &expiresAt,
+	})
+
+	link := dataExportBaseURL + "/exports/" + token
+	mail := email.Email{
+		Title: dataExportEmailTitle,
+		Body:  fmt.Sprintf(dataExportEmailBody, member.LoginID, link, link),
+		To:    member.Email,
+	}
+	mail.Send()
+}
+
+// writeDataExport는 회원의 정보를 JSON 파일로, 업로드한 파일은 원본 그대로 ZIP 파일에 담습니다.
+func writeDataExport(export *DataExport, member *Member) error {
+	if err := os.MkdirAll(dataExportBaseDir, 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(export.filePath(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	writeJSON := func(name string, v interface{}) error {
+		w, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	var posts []Post
+	database.DB.Where(&Post{AuthorUUID: member.UUID}).Order("id").Find(&posts)
+	var comments []Comment
+	database.DB.Where(&Comment{AuthorUUID: member.UUID}).Order("id").Find(&comments)
+	var boardSubscriptions []BoardSubscription
+	database.DB.Where(&BoardSubscription{MemberUUID: member.UUID}).Find(&boardSubscriptions)
+	var postSubscriptions []PostSubscription
+	database.DB.Where(&PostSubscription{MemberUUID: member.UUID}).Find(&postSubscriptions)
+
+	type voteSelection struct {
+		VoteID     int
+		VoteTitle  string
+		OptionID   int
+		OptionText string
+		CreatedAt  time.Time
+	}
+	var voteSelections []voteSelection
+	database.DB.Table("vote_selections").
+		Select("votes.id AS vote_id, votes.title AS vote_title, vote_options.id AS option_id, vote_options.text AS option_text, vote_selections.created_at").
+		Joins("JOIN votes ON votes.id = vote_selections.vote_id").
+		Joins("JOIN vote_options ON vote_options.id = vote_selections.vote_option_id").
+		Where("vote_selections.member_uuid = ?", member.UUID).Order("vote_selections.created_at").Scan(&voteSelections)
+
+	var uploadedFiles []UploadedFile
+	database.DB.Where(&UploadedFile{MemberUUID: member.UUID}).Order("created_at").Find(&uploadedFiles)
+	fileNames := []string{}
+	for _, uploaded := range uploadedFiles {
+		fileNames = append(fileNames, uploaded.FileName)
+	}
+	if member.AvatarFileName != "" && !containsString(fileNames, member.AvatarFileName) {
+		fileNames = append(fileNames, member.AvatarFileName)
+	}
+
+	// Secrets such as password hashes and tokens are left out.
+	profile := map[string]interface{}{
+		"uuid":            member.UUID,
+		"loginID":         member.LoginID,
+		"name":            member.Name,
+		"email":           member.Email,
+		"isEmailVerified": member.IsEmailVerified,
+		"phoneNumber":     member.PhoneNumber,
+		"department":      member.Department,
+		"studentID":       member.StudentID,
+		"generation":      member.Generation,
+		"bio":             member.Bio,
+		"githubURL":       member.GithubURL,
+		"blogURL":         member.BlogURL,
+		"avatarFileName":  member.AvatarFileName,
+		"isActivated":     member.IsActivated,
+		"isTOTPEnabled":   member.IsTOTPEnabled,
+		"roles":           getMemberRoles(member.UUID),
+		"privacy": map[string]string{
+			"email":       member.EmailVisibility,
+			"phoneNumber": member.PhoneNumberVisibility,
+			"studentID":   member.StudentIDVisibility,
+		},
+		"createdAt": member.CreatedAt,
+		"updatedAt": member.UpdatedAt,
+	}
+
+	for name, v := range map[string]interface{}{
+		"profile.json":         profile,
+		"posts.json":           posts,
+		"comments.json":        comments,
+		"vote_selections.json": voteSelections,
+		"subscriptions.json": map[string]interface{}{
+			"boards": boardSubscriptions,
+			"posts":  postSubscriptions,
+		},
+		"files.json": uploadedFiles,
+	} {
+		if err := writeJSON(name, v); err != nil {
+			return err
+		}
+	}
+
+	for _, fileName := range fileNames {
+		if !fileExists(fileName) {
+			continue
+		}
+		content, err := ioutil.ReadFile(fileBaseDir + "/" + fileName)
+		if err != nil {
+			return err
+		}
+		w, err := archive.Create("files/" + fileName)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// cleanDataExports는 링크가 만료되었거나 기록이 지워진 개인 정보 파일을 지웁니다.
+func cleanDataExports() {
+	var exports []DataExport
+	database.DB.Where("status = ? AND expires_at < ?", DataExportStatusReady, time.Now()).Find(&exports)
+	for _, export := range exports {
+		os.Remove(export.filePath())
+		database.DB.Delete(&export)
+	}
+
+	// Files left behind when a member was withdrawn or merged, or when a build was interrupted.
+	failStaleDataExports()
+	removeOrphanedDataExportFiles()
+}
+
+// failStaleDataExports는 dataExportBuildTimeout이 지나도록 PENDING인 내보내기를 실패 처리합니다.
+func failStaleDataExports() {
+	var exports []DataExport
+	database.DB.Where("status = ? AND created_at < ?", DataExportStatusPending, time.Now().Add(-dataExportBuildTimeout)).Find(&exports)
+	for _, export := range exports {
+		os.Remove(export.filePath())
+		database.DB.Model(&export).Update("status", DataExportStatusFailed)
+	}
+}
+
+// removeOrphanedDataExportFiles는 기록이 지워진 내보내기 파일을 지웁니다.
+// 기록을 지우는 트랜잭션이 커밋된 뒤에 호출해야, 커밋에 실패했을 때 파일만 사라지는 일이 없습니다.
+func removeOrphanedDataExportFiles() {
+	paths, _ := filepath.Glob(filepath.Join(dataExportBaseDir, "*.zip"))
+	for _, path := range paths {
+		id, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(path), ".zip"))
+		if err != nil {
+			continue
+		}
+
+		var count int
+		database.DB.Model(&DataExport{}).Where("id = ?", id).Count(&count)
+		if count == 0 {
+			os.Remove(path)
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	var ok bool
+	dataExportBaseDir, ok = os.LookupEnv("NAGASE_EXPORTS_DIR")
+	if !ok {
+		dataExportBaseDir = "/data/nagase/exports"
+	}
+	dataExportBaseURL = strings.TrimSuffix(os.Getenv("NAGASE_BASE_URL"), "/")
+	if dataExportBaseURL == "" {
+		dataExportBaseURL = "http://localhost:8080"
+	}
+
+	go func() {
+		for range time.Tick(dataExportCleanInterval) {
+			cleanDataExports()
+		}
+	}()
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/graphql-go/graphql"
+
+	"nagase/components/auth"
+	"nagase/components/database"
+	"nagase/components/email"
+)
+
+const (
+	challengePurposeEmailVerification = "email-verification"
+	emailVerificationLifetime         = 24 * time.Hour
+)
+
+var emailVerificationEmailTitle = "이메일 주소 확인 안내"
+var emailVerificationEmailBody = `
+안녕하세요,
+PoolC 홈페이지 이메일 주소 확인 안내 메일입니다.
+
+아래 링크를 눌러 %s 계정의 이메일 주소를 확인해주세요.
+<a href="https://poolc.org/accounts/verify-email?token=%s">https://poolc.org/accounts/verify-email?token=%s</a>
+링크는 24시간 동안 유효합니다.
+
+본인이 요청하지 않은 경우, 이 메일을 무시해주세요.
+감사합니다.
+`
+
+// Mutations
+var VerifyEmailMutation = &graphql.Field{
+	Type:        memberType,
+	Description: "이메일 주소 확인 링크의 토큰으로 이메일 주소를 확인합니다. 변경을 요청한 이메일 주소는 확인한 뒤에 회원 정보에 반영됩니다.",
+	Args: graphql.FieldConfigArgument{
+		"token": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		claims, err := auth.ValidatedChallengeToken(params.Args["token"].(string), challengePurposeEmailVerification)
+		if err != nil {
+			return nil, fmt.Errorf("MEM002")
+		}
+		member, err := GetMemberByUUID(claims.MemberUUID)
+		if err != nil {
+			return nil, fmt.Errorf("MEM002")
+		}
+
+		// The link is only valid for the address it was sent to.
+		address := claims.Data["email"]
+		switch address {
+		case member.Email:
+			member.IsEmailVerified = true
+		case member.PendingEmail:
+			var duplicated Member
+			database.DB.Where(&Member{Email: address}).First(&duplicated)
+			if duplicated.UUID != "" {
+				return nil, fmt.Errorf("MEM001")
+			}
+
+			member.Email = address
+			member.PendingEmail = ""
+			member.IsEmailVerified = true
+		default:
+			return nil, fmt.Errorf("MEM002")
+		}
+
+		errs := database.DB.Save(member).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return member, nil
+	},
+}
+
+var RequestEmailVerificationMutation = &graphql.Field{
+	Type:        graphql.Boolean,
+	Description: "이메일 주소 확인 메일을 다시 발송합니다. 변경을 요청한 이메일 주소가 있으면 그 주소로 발송합니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member, _ := GetMemberByUUID(params.Context.Value("member").(*Member).UUID)
+
+		if member.PendingEmail != "" {
+			return true, sendEmailVerification(member, member.PendingEmail)
+		} else if !member.IsEmailVerified {
+			return true, sendEmailVerification(member, member.Email)
+		}
+		return false, nil
+	},
+}
+
+// Common functions
+
+// sendEmailVerification은 주소 확인 링크를 이메일로 발송합니다. 링크의 토큰에는 확인할 주소가 담겨 있습니다.
+func sendEmailVerification(member *Member, address string) error {
+	token, err := auth.GenerateChallengeToken(member.UUID, challengePurposeEmailVerification, map[string]string{"email": address}, emailVerificationLifetime)
+	if err != nil {
+		return fmt.Errorf("ERR500")
+	}
+
+	mail := email.Email{
+		Title: emailVerificationEmailTitle,
+		Body:  fmt.Sprintf(emailVerificationEmailBody, member.LoginID, token, token),
+		To:    address,
+	}
+	go func() { mail.Send() }()
+	return nil
+}
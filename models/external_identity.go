@@ -0,0 +1,190 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	uuid "github.com/satori/go.uuid"
+
+	"nagase/components/auth"
+	"nagase/components/database"
+	"nagase/components/oidc"
+	"nagase/components/random"
+)
+
+const (
+	challengePurposeExternalLink = "oidc-link"
+
+	// 외부 로그인 결과
+	ExternalLoginStatusLoggedIn = "LOGGED_IN"
+	ExternalLoginStatusLinked   = "LINKED"
+	ExternalLoginStatusPending  = "PENDING"
+)
+
+// ExternalIdentity는 Google, GitHub 등 외부 로그인 제공자의 계정과 회원의 연결입니다.
+type ExternalIdentity struct {
+	ID int
+
+	Provider   string `gorm:"type:varchar(40);UNIQUE_INDEX:idx_external_identity_subject"`
+	Subject    string `gorm:"type:varchar(255);UNIQUE_INDEX:idx_external_identity_subject"`
+	MemberUUID string `gorm:"type:varchar(40);INDEX"`
+	Email      string `gorm:"type:varchar(255)"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+var externalIdentityType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ExternalIdentity",
+	Fields: graphql.Fields{
+		"provider":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"email":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+	},
+})
+
+// Queries
+var MyExternalIdentitiesQuery = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(externalIdentityType))),
+	Description: "자신의 계정에 연결된 외부 로그인 목록을 조회합니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		var identities []ExternalIdentity
+		database.DB.Where(&ExternalIdentity{MemberUUID: member.UUID}).Order("id asc").Find(&identities)
+		return identities, nil
+	},
+}
+
+// Mutations
+var CreateExternalLinkTokenMutation = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.String),
+	Description: "외부 로그인 계정을 연결하기 위한 토큰을 발급합니다. `/auth/{provider}/login?link_token={token}`으로 이동하면 로그인한 외부 계정이 자신의 계정에 연결됩니다. 토큰은 5분 동안 유효합니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		token, err := auth.GenerateChallengeToken(member.UUID, challengePurposeExternalLink, nil, challengeTokenLifetime)
+		if err != nil {
+			return nil, fmt.Errorf("ERR500")
+		}
+		return token, nil
+	},
+}
+
+var UnlinkExternalIdentityMutation = &graphql.Field{
+	Type:        externalIdentityType,
+	Description: "외부 로그인 계정의 연결을 해제합니다.",
+	Args: graphql.FieldConfigArgument{
+		"provider": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		var identity ExternalIdentity
+		database.DB.Where(&ExternalIdentity{MemberUUID: member.UUID, Provider: params.Args["provider"].(string)}).First(&identity)
+		if identity.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		database.DB.Delete(&identity)
+		return identity, nil
+	},
+}
+
+// Common functions
+
+// GetMemberUUIDByExternalLinkToken은 계정 연결 토큰을 검증하고 회원의 UUID를 반환합니다.
+func GetMemberUUIDByExternalLinkToken(tokenString string) (string, error) {
+	member, err := getMemberByChallengeToken(tokenString, challengePurposeExternalLink)
+	if err != nil {
+		return "", err
+	}
+	return member.UUID, nil
+}
+
+// LoginWithExternalIdentity는 외부 로그인 제공자가 확인한 계정으로 로그인합니다.
+//   - linkMemberUUID가 주어지면 외부 계정을 해당 회원에 연결합니다.
+//   - 연결된 회원이 있으면 로그인하여 토큰을 발급합니다. (2단계 인증을 사용하는 경우 challenge token)
+//   - 연결된 회원이 없으면 활성화되지 않은 회원을 새로 만들고, 관리자의 승인을 기다립니다.
+func LoginWithExternalIdentity(ctx context.Context, provider string, identity *oidc.Identity, linkMemberUUID string) (*AccessToken, string, error) {
+	var existing ExternalIdentity
+	database.DB.Where(&ExternalIdentity{Provider: provider, Subject: identity.Subject}).First(&existing)
+
+	if linkMemberUUID != "" {
+		if existing.ID != 0 && existing.MemberUUID != linkMemberUUID {
+			return nil, "", fmt.Errorf("OID001")
+		}
+		if existing.ID == 0 {
+			errs := database.DB.Create(&ExternalIdentity{Provider: provider, Subject: identity.Subject, MemberUUID: linkMemberUUID, Email: identity.Email}).GetErrors()
+			if len(errs) > 0 {
+				return nil, "", errs[0]
+			}
+		}
+		return nil, ExternalLoginStatusLinked, nil
+	}
+
+	if existing.ID != 0 {
+		member, err := GetMemberByUUID(existing.MemberUUID)
+		if err != nil {
+			return nil, "", fmt.Errorf("OID000")
+		} else if !member.IsActivated {
+			return nil, ExternalLoginStatusPending, nil
+		}
+
+		token, err := issueLoginToken(ctx, member)
+		if err != nil {
+			return nil, "", err
+		}
+		return token, ExternalLoginStatusLoggedIn, nil
+	}
+
+	// Create a pending member, which should be activated by administrators.
+	if identity.Email == "" {
+		return nil, "", fmt.Errorf("OID002")
+	}
+	var duplicated Member
+	database.DB.Where(&Member{Email: identity.Email}).First(&duplicated)
+	if duplicated.UUID != "" {
+		return nil, "", fmt.Errorf("MEM001")
+	}
+
+	// The member has no password, and cannot log in with a password until resetting it.
+	loginID := provider + "_" + strings.ToLower(random.GenerateRandomString(12))
+	member := Member{
+		UUID:        uuid.NewV4().String(),
+		LoginID:     loginID,
+		Email:       identity.Email,
+		Name:        identity.Name,
+		StudentID:   "_" + loginID,
+		IsActivated: false,
+
+		IsEmailVerified: identity.EmailVerified,
+	}
+
+	tx := database.DB.Begin()
+	if errs := tx.Create(&member).GetErrors(); len(errs) > 0 {
+		tx.Rollback()
+		return nil, "", errs[0]
+	}
+	if errs := tx.Create(&ExternalIdentity{Provider: provider, Subject: identity.Subject, MemberUUID: member.UUID, Email: identity.Email}).GetErrors(); len(errs) > 0 {
+		tx.Rollback()
+		return nil, "", errs[0]
+	}
+	if errs := tx.Commit().GetErrors(); len(errs) > 0 {
+		return nil, "", errs[0]
+	}
+
+	return nil, ExternalLoginStatusPending, nil
+}
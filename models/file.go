@@ -5,12 +5,24 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/h2non/filetype"
+
+	"nagase/components/database"
 )
 
 var fileBaseDir string
 
+// UploadedFile은 /files/에 업로드된 파일과 업로드한 회원의 기록입니다.
+type UploadedFile struct {
+	FileName   string `gorm:"type:varchar(255);PRIMARY_KEY"`
+	MemberUUID string `gorm:"type:varchar(40);INDEX"`
+
+	CreatedAt time.Time
+}
+
 func GetFile(fileName string) (buffer *bytes.Buffer, contentType string, err error) {
 	// Return error if file not exists.
 	if _, err := os.Stat(fileBaseDir + "/" + fileName); os.IsNotExist(err) {
@@ -27,12 +39,41 @@ func GetFile(fileName string) (buffer *bytes.Buffer, contentType string, err err
 	return bytes.NewBuffer(file), kind.MIME.Value, nil
 }
 
-func SaveFile(buffer *bytes.Buffer, fileName string) error {
+func fileExists(fileName string) bool {
+	if fileName == "" || strings.Contains(fileName, "/") {
+		return false
+	}
+	_, err := os.Stat(fileBaseDir + "/" + fileName)
+	return err == nil
+}
+
+// ownsFile은 회원이 직접 업로드한 파일인지 확인합니다.
+func (member *Member) ownsFile(fileName string) bool {
+	if !fileExists(fileName) {
+		return false
+	}
+
+	var count int
+	database.DB.Model(&UploadedFile{}).Where("file_name = ? AND member_uuid = ?", fileName, member.UUID).Count(&count)
+	return count > 0
+}
+
+// deleteFile은 업로드된 파일을 지웁니다. 파일이 없으면 아무것도 하지 않습니다.
+func deleteFile(fileName string) {
+	if fileExists(fileName) {
+		os.Remove(fileBaseDir + "/" + fileName)
+	}
+}
+
+func SaveFile(buffer *bytes.Buffer, fileName string, memberUUID string) error {
 	// Return error if file exists.
 	if _, err := os.Stat(fileBaseDir + "/" + fileName); !os.IsNotExist(err) {
 		return fmt.Errorf("file already exists")
 	}
-	return ioutil.WriteFile(fileBaseDir+"/"+fileName, buffer.Bytes(), 0644)
+	if err := ioutil.WriteFile(fileBaseDir+"/"+fileName, buffer.Bytes(), 0644); err != nil {
+		return err
+	}
+	return database.DB.Create(&UploadedFile{FileName: fileName, MemberUUID: memberUUID}).Error
 }
 
 func init() {
@@ -46,14 +46,46 @@ func getPaginationFromGraphQLParams(params *graphql.ResolveParams) *Pagination {
 func init() {
 	database.DB.AutoMigrate(
 		&Board{},
+		&BoardCategory{},
+		&BoardModerator{},
+		&MemberGroup{},
+		&MemberGroupMembership{},
+		&BoardGroupAccess{},
 		&BoardSubscription{},
 		&Member{},
 		&Post{},
+		&PostSlug{},
 		&PostSubscription{},
 		&Comment{},
 		&Vote{},
 		&VoteOption{},
 		&VoteSelection{},
 		&Project{},
+		&ProjectParticipant{},
+		&Session{},
+		&UsedRefreshToken{},
+		&RecoveryCode{},
+		&UsedChallengeToken{},
+		&ExternalIdentity{},
+		&LoginAttempt{},
+		&PersonalAccessToken{},
+		&Role{},
+		&RolePermission{},
+		&MemberRole{},
+		&InviteCode{},
+		&ApplicationForm{},
+		&ApplicationQuestion{},
+		&Application{},
+		&ApplicationAnswer{},
+		&ApplicationReview{},
+		&Term{},
+		&TermEnrollment{},
+		&DuesPayment{},
+		&UploadedFile{},
+		&DataExport{},
+		&LegacyAccountClaim{},
 	)
+	migrateRoles()
+	migratePasswords()
+	migratePostSlugs()
 }
@@ -0,0 +1,271 @@
+package models
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/jinzhu/gorm"
+
+	"nagase/components/database"
+)
+
+// NAGASE_REQUIRE_INVITE_CODE가 true이면, 초대 코드 없이는 회원가입할 수 없습니다.
+var isInviteCodeRequired = os.Getenv("NAGASE_REQUIRE_INVITE_CODE") == "true"
+
+const inviteCodeLetters = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// InviteCode는 관리자가 발급하는 회원가입 초대 코드입니다.
+// 올바른 초대 코드로 가입한 회원은 관리자의 승인 없이 바로 활성화됩니다.
+type InviteCode struct {
+	ID int
+
+	Code        string `gorm:"type:varchar(40);UNIQUE_INDEX"`
+	Description string `gorm:"type:varchar(255)"`
+	MaxUses     int
+	UsedCount   int `gorm:"NOT NULL;default:0"`
+	ExpiresAt   *time.Time
+	RevokedAt   *time.Time
+
+	// 이 코드로 가입한 회원에게 부여할 역할과 학과
+	RoleID     *int
+	Department string `gorm:"type:varchar(40)"`
+
+	CreatedByUUID string `gorm:"type:varchar(40)"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (code *InviteCode) IsValid() bool {
+	return code.RevokedAt == nil &&
+		(code.ExpiresAt == nil || code.ExpiresAt.After(time.Now())) &&
+		code.UsedCount < code.MaxUses
+}
+
+var inviteCodeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "InviteCode",
+	Fields: graphql.Fields{
+		"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"code":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"description": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"maxUses":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"usedCount":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"expiresAt":   &graphql.Field{Type: graphql.DateTime},
+		"revokedAt":   &graphql.Field{Type: graphql.DateTime},
+		"department":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"createdAt":   &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"isValid": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				code := params.Source.(InviteCode)
+				return code.IsValid(), nil
+			},
+		},
+		"role": &graphql.Field{
+			Type: roleType,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				code := params.Source.(InviteCode)
+				if code.RoleID == nil {
+					return nil, nil
+				}
+
+				var role Role
+				database.DB.Where(&Role{ID: *code.RoleID}).First(&role)
+				if role.ID == 0 {
+					return nil, nil
+				}
+				return role, nil
+			},
+		},
+	},
+})
+
+func init() {
+	inviteCodeType.AddFieldConfig("members", &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(memberType))),
+		Description: "이 초대 코드로 가입한 회원 목록",
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			code := params.Source.(InviteCode)
+
+			var members []Member
+			database.DB.Where(&Member{InviteCodeID: &code.ID}).Order("created_at asc").Find(&members)
+			return members, nil
+		},
+	})
+}
+
+var inviteCodeInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "InviteCodeInput",
+	Description: "초대 코드 발급 InputObject",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"code": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "지정하지 않으면 임의의 코드를 발급합니다.",
+		},
+		"description": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"maxUses": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Int,
+			Description: "코드를 사용할 수 있는 횟수 (기본값 1)",
+		},
+		"expiresInDays": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Int,
+			Description: "코드의 유효 기간. 지정하지 않으면 만료되지 않습니다.",
+		},
+		"roleID": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Int,
+			Description: "가입한 회원에게 부여할 역할. roles.manage 권한이 필요합니다.",
+		},
+		"department": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "가입한 회원의 학과",
+		},
+	},
+})
+
+// Queries
+var InviteCodesQuery = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(inviteCodeType))),
+	Description: "초대 코드 목록을 조회합니다. members.invite 권한이 필요합니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionInviteMembers) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var codes []InviteCode
+		database.DB.Order("created_at desc").Find(&codes)
+		return codes, nil
+	},
+}
+
+// Mutations
+var CreateInviteCodeMutation = &graphql.Field{
+	Type:        inviteCodeType,
+	Description: "초대 코드를 발급합니다. members.invite 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"InviteCodeInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(inviteCodeInputType)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionInviteMembers) {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		input := params.Args["InviteCodeInput"].(map[string]interface{})
+		code := InviteCode{MaxUses: 1, CreatedByUUID: member.UUID}
+		if input["code"] != nil {
+			code.Code = strings.ToUpper(strings.TrimSpace(input["code"].(string)))
+		} else {
+			code.Code = generateInviteCode()
+		}
+		if input["description"] != nil {
+			code.Description = input["description"].(string)
+		}
+		if input["maxUses"] != nil {
+			code.MaxUses = input["maxUses"].(int)
+		}
+		if input["expiresInDays"] != nil {
+			expiresAt := time.Now().AddDate(0, 0, input["expiresInDays"].(int))
+			code.ExpiresAt = &expiresAt
+		}
+		if input["department"] != nil {
+			code.Department = input["department"].(string)
+		}
+		if input["roleID"] != nil {
+			// Assigning a role by code is the same as assigning it directly.
+			if !member.Can(PermissionManageRoles) {
+				return nil, fmt.Errorf("ERR403")
+			}
+
+			var role Role
+			database.DB.Where(&Role{ID: input["roleID"].(int)}).First(&role)
+			if role.ID == 0 || role.Name == AdminRoleName {
+				return nil, fmt.Errorf("ERR400")
+			}
+			code.RoleID = &role.ID
+		}
+		if code.Code == "" || code.MaxUses < 1 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		var duplicated InviteCode
+		database.DB.Where(&InviteCode{Code: code.Code}).First(&duplicated)
+		if duplicated.ID != 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		errs := database.DB.Create(&code).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return code, nil
+	},
+}
+
+var RevokeInviteCodeMutation = &graphql.Field{
+	Type:        inviteCodeType,
+	Description: "초대 코드를 더 이상 사용할 수 없게 합니다. members.invite 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"inviteCodeID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionInviteMembers) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var code InviteCode
+		database.DB.Where(&InviteCode{ID: params.Args["inviteCodeID"].(int)}).First(&code)
+		if code.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		if code.RevokedAt == nil {
+			now := time.Now()
+			code.RevokedAt = &now
+			errs := database.DB.Save(&code).GetErrors()
+			if len(errs) > 0 {
+				return nil, errs[0]
+			}
+		}
+		return code, nil
+	},
+}
+
+// Common functions
+func generateInviteCode() string {
+	b := make([]byte, 12)
+	for i := range b {
+		n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(inviteCodeLetters))))
+		b[i] = inviteCodeLetters[n.Int64()]
+	}
+	return string(b[:4]) + "-" + string(b[4:8]) + "-" + string(b[8:])
+}
+
+// getValidInviteCode는 사용할 수 있는 초대 코드를 반환합니다.
+func getValidInviteCode(code string) (*InviteCode, error) {
+	var inviteCode InviteCode
+	database.DB.Where(&InviteCode{Code: strings.ToUpper(strings.TrimSpace(code))}).First(&inviteCode)
+	if inviteCode.ID == 0 || !inviteCode.IsValid() {
+		return nil, fmt.Errorf("MEM003")
+	}
+	return &inviteCode, nil
+}
+
+// useInviteCodeWithDB는 초대 코드의 사용 횟수를 올리고, 코드에 지정된 역할을 회원에게 부여합니다.
+// 동시에 가입하는 경우에도 사용 횟수를 넘지 않도록, 조건부 UPDATE로 횟수를 올립니다.
+func useInviteCodeWithDB(db *gorm.DB, code *InviteCode, member *Member) error {
+	query := db.Model(&InviteCode{}).Where("id = ? and used_count < max_uses", code.ID).UpdateColumn("used_count", gorm.Expr("used_count + 1"))
+	if query.Error != nil {
+		return query.Error
+	} else if query.RowsAffected != 1 {
+		return fmt.Errorf("MEM003")
+	}
+
+	if code.RoleID != nil {
+		return db.Create(&MemberRole{MemberUUID: member.UUID, RoleID: *code.RoleID}).Error
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/graphql-go/graphql"
+
+	"nagase/components/database"
+	"nagase/components/email"
+)
+
+// 로그인 실패 횟수에 따른 제한 정책
+//   - 실패가 threshold회를 넘으면, 실패할 때마다 다음 시도까지 기다려야 하는 시간이 두 배씩 늘어납니다.
+//   - 한 계정에서 accountLockThreshold회 실패하면 계정이 accountLockDuration 동안 잠기고, 회원에게 안내 메일이 발송됩니다.
+//   - 마지막 실패로부터 loginAttemptWindow가 지나면 실패 횟수를 다시 셉니다.
+const (
+	accountBackoffThreshold = 3
+	accountLockThreshold    = 10
+	accountLockDuration     = 30 * time.Minute
+	ipBackoffThreshold      = 20
+	loginMaxBackoff         = 15 * time.Minute
+	loginAttemptWindow      = time.Hour
+)
+
+// LoginAttempt는 계정 또는 IP별 로그인 실패 기록입니다.
+// Key는 `member:{uuid}` 또는 `ip:{address}` 형태입니다.
+type LoginAttempt struct {
+	Key string `gorm:"type:varchar(80);PRIMARY_KEY"`
+
+	FailedCount  int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+
+	UpdatedAt time.Time
+}
+
+func (attempt *LoginAttempt) isLocked() bool {
+	return attempt.LockedUntil != nil && attempt.LockedUntil.After(time.Now())
+}
+
+// backoff는 마지막 실패 이후 다음 시도까지 기다려야 하는 시간을 반환합니다.
+func (attempt *LoginAttempt) backoff(threshold int) time.Duration {
+	if attempt.FailedCount < threshold {
+		return 0
+	}
+
+	exponent := uint(attempt.FailedCount - threshold)
+	if exponent > 10 {
+		return loginMaxBackoff
+	}
+	if wait := time.Second << exponent; wait < loginMaxBackoff {
+		return wait
+	}
+	return loginMaxBackoff
+}
+
+var accountLockedEmailTitle = "계정 잠금 안내"
+var accountLockedEmailBody = `
+안녕하세요,
+PoolC 홈페이지 계정 잠금 안내 메일입니다.
+
+%s 계정에서 로그인 실패가 반복되어, 계정이 %s까지 잠겼습니다.
+마지막 로그인 시도 IP : %s
+
+본인이 로그인을 시도하지 않은 경우, 비밀번호를 변경하고 관리자에게 알려주세요.
+감사합니다.
+`
+
+// Mutations
+var UnlockMemberMutation = &graphql.Field{
+	Type:        memberType,
+	Description: "로그인 실패로 잠긴 계정의 잠금을 해제합니다. members.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"memberUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageMembers) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		member, err := GetMemberByUUID(params.Args["memberUUID"].(string))
+		if err != nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		resetLoginAttempts(memberLoginAttemptKey(member.UUID))
+		return member, nil
+	},
+}
+
+// Common functions
+func memberLoginAttemptKey(memberUUID string) string {
+	return "member:" + memberUUID
+}
+
+// ipLoginAttemptKey는 IP 주소를 정규화한 키를 반환합니다. 올바르지 않은 주소는 모두 하나의 키로 셉니다.
+func ipLoginAttemptKey(ipAddress string) string {
+	if ip := net.ParseIP(ipAddress); ip != nil {
+		return "ip:" + ip.String()
+	}
+	return "ip:unknown"
+}
+
+func getLoginAttempt(key string) LoginAttempt {
+	var attempt LoginAttempt
+	database.DB.Where(&LoginAttempt{Key: key}).First(&attempt)
+	if attempt.Key == "" || (!attempt.isLocked() && time.Since(attempt.LastFailedAt) > loginAttemptWindow) {
+		return LoginAttempt{Key: key}
+	}
+	return attempt
+}
+
+// checkLoginAllowed는 로그인을 시도할 수 없는 경우 에러 코드를 반환합니다.
+func checkLoginAllowed(key string, threshold int) error {
+	attempt := getLoginAttempt(key)
+	if attempt.isLocked() {
+		return fmt.Errorf("TKN006")
+	}
+	if attempt.LastFailedAt.Add(attempt.backoff(threshold)).After(time.Now()) {
+		return fmt.Errorf("TKN005")
+	}
+	return nil
+}
+
+func recordLoginFailure(key string) LoginAttempt {
+	attempt := getLoginAttempt(key)
+	attempt.FailedCount++
+	attempt.LastFailedAt = time.Now()
+	database.DB.Save(&attempt)
+	return attempt
+}
+
+func resetLoginAttempts(key string) {
+	database.DB.Where(&LoginAttempt{Key: key}).Delete(LoginAttempt{})
+}
+
+// checkMemberLoginAllowed는 회원과 접속 IP의 로그인 실패 기록을 확인합니다.
+func checkMemberLoginAllowed(member *Member, ipAddress string) error {
+	if err := checkLoginAllowed(ipLoginAttemptKey(ipAddress), ipBackoffThreshold); err != nil {
+		return err
+	}
+	if member != nil {
+		return checkLoginAllowed(memberLoginAttemptKey(member.UUID), accountBackoffThreshold)
+	}
+	return nil
+}
+
+// recordMemberLoginFailure는 로그인 실패를 기록하고, 실패가 반복된 계정을 잠급니다.
+func recordMemberLoginFailure(member *Member, ipAddress string) {
+	recordLoginFailure(ipLoginAttemptKey(ipAddress))
+	if member == nil {
+		return
+	}
+
+	attempt := recordLoginFailure(memberLoginAttemptKey(member.UUID))
+	if attempt.FailedCount >= accountLockThreshold && !attempt.isLocked() {
+		lockedUntil := time.Now().Add(accountLockDuration)
+		attempt.LockedUntil = &lockedUntil
+		database.DB.Save(&attempt)
+
+		mail := email.Email{
+			Title: accountLockedEmailTitle,
+			Body:  fmt.Sprintf(accountLockedEmailBody, member.LoginID, lockedUntil.Format("2006-01-02 15:04"), ipAddress),
+			To:    member.Email,
+		}
+		go func() { mail.Send() }()
+	}
+}
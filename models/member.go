@@ -1,45 +1,144 @@
 package models
 
 import (
-	"bytes"
-	"crypto/rand"
 	"fmt"
 	"time"
 
 	"github.com/graphql-go/graphql"
+	"github.com/jinzhu/gorm"
 	uuid "github.com/satori/go.uuid"
-	"golang.org/x/crypto/argon2"
 
 	"nagase/components/database"
 	"nagase/components/email"
+	"nagase/components/password"
 	"nagase/components/random"
 )
 
 type Member struct {
 	UUID string `gorm:"type:varchar(40);PRIMARY_KEY"`
 
-	LoginID      string `gorm:"type:varchar(40);UNIQUE_INDEX"`
-	PasswordHash []byte `gorm:"NOT NULL" json:"-"`
-	PasswordSalt []byte `gorm:"NOT NULL" json:"-"`
-	Email        string `gorm:"type:varchar(255);UNIQUE_INDEX"`
-	PhoneNumber  string `gorm:"type:varchar(20)"`
-	Name         string `gorm:"type:varchar(40)"`
-	Department   string `gorm:"type:varchar(40)"`
-	StudentID    string `gorm:"type:varchar(40);UNIQUE_INDEX"`
+	LoginID        string `gorm:"type:varchar(40);UNIQUE_INDEX"`
+	HashedPassword string `gorm:"type:varchar(255);NOT NULL;default:''" json:"-"` // PHC 문자열 형태의 해시
+	Email          string `gorm:"type:varchar(255);UNIQUE_INDEX"`
+	PhoneNumber    string `gorm:"type:varchar(20)"`
+	Name           string `gorm:"type:varchar(40)"`
+	Department     string `gorm:"type:varchar(40)"`
+	StudentID      string `gorm:"type:varchar(40);UNIQUE_INDEX"`
 
 	IsActivated bool `gorm:"default:false"`
-	IsAdmin     bool `gorm:"default:false"`
+
+	// 봇이나 스크립트가 사용하는 서비스 계정. roles.manage 권한이 있는 회원이 대신 personal access token을 발급할 수 있습니다.
+	IsServiceAccount bool `gorm:"default:false"`
+
+	// 프로필 정보
+	AvatarFileName string `gorm:"type:varchar(255)"`
+	Bio            string `gorm:"type:text"`
+	GithubURL      string `gorm:"type:varchar(255)"`
+	BlogURL        string `gorm:"type:varchar(255)"`
+	Generation     *int
+
+	// 회원 정보 공개 범위. 본인은 항상 볼 수 있습니다.
+	EmailVisibility       string `gorm:"type:varchar(20);NOT NULL;default:'MEMBERS'"`
+	PhoneNumberVisibility string `gorm:"type:varchar(20);NOT NULL;default:'ADMINS'"`
+	StudentIDVisibility   string `gorm:"type:varchar(20);NOT NULL;default:'ADMINS'"`
+
+	// 가입할 때 사용한 초대 코드
+	InviteCodeID *int
+
+	// 이메일 주소 확인 정보. 변경을 요청한 주소는 확인할 때까지 PendingEmail에 보관합니다.
+	IsEmailVerified bool   `gorm:"default:false"`
+	PendingEmail    string `gorm:"type:varchar(255)"`
+
+	// 2단계 인증(TOTP) 정보
+	TOTPSecret       string `gorm:"type:varchar(64)" json:"-"`
+	TOTPLastUsedStep int64  `json:"-"`
+	IsTOTPEnabled    bool   `gorm:"default:false"`
+	IsTOTPRequired   bool   `gorm:"default:false"`
+
+	// 보안과 관련된 정보가 바뀔 때마다 증가하며, 이전 버전으로 발급된 토큰은 거부됩니다.
+	TokenVersion int `gorm:"NOT NULL;default:0"`
 
 	PasswordResetToken           string `gorm:"type:varchar(255)"`
 	PasswordResetTokenValidUntil time.Time
 
+	// 회원 탈퇴 정보. 탈퇴를 신청하고 유예 기간이 지나면 개인 정보가 지워지고 WithdrawnAt이 기록됩니다.
+	WithdrawalRequestedAt *time.Time
+	WithdrawnAt           *time.Time
+
+	// 다른 회원에 병합된 경우, 병합된 회원의 UUID
+	MergedIntoUUID string `gorm:"type:varchar(40)"`
+
+	// 역할에서 가져온 권한. Can 함수에서 처음 사용할 때 채워집니다.
+	grantedPermissions map[string]bool
+
+	// 현재 학기 등록 여부. hasActiveMembership 함수에서 처음 사용할 때 채워집니다.
+	activeMembership *bool
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
-func (member Member) ValidatePassword(password string) bool {
-	hash := argon2.IDKey([]byte(password), member.PasswordSalt, 1, 8*1024, 4, 32)
-	return bytes.Compare(hash, member.PasswordHash) == 0
+// ValidatePassword는 비밀번호를 확인합니다.
+// 해시의 알고리즘이나 파라미터가 오래된 경우, 확인한 비밀번호로 다시 해시하여 저장합니다.
+func (member *Member) ValidatePassword(plain string) bool {
+	ok, rehash := password.Verify(plain, member.HashedPassword)
+	if ok && rehash {
+		if hashed, err := password.Hash(plain); err == nil {
+			// Update the column directly, as the password itself has not been changed.
+			member.HashedPassword = hashed
+			database.DB.Model(member).UpdateColumn("hashed_password", hashed)
+		}
+	}
+	return ok
+}
+
+// SetPassword는 비밀번호를 해시하여 설정합니다. DB에는 저장하지 않습니다.
+func (member *Member) SetPassword(plain string) error {
+	hashed, err := password.Hash(plain)
+	if err != nil {
+		return err
+	}
+	member.HashedPassword = hashed
+	return nil
+}
+
+// BeforeUpdate는 비밀번호나 활성화 여부가 바뀐 경우 토큰 버전을 올리고
+// 회원의 모든 세션을 폐기하여, 기존에 발급된 토큰을 더 이상 사용할 수 없게 합니다.
+func (member *Member) BeforeUpdate(scope *gorm.Scope) error {
+	// Updates without a primary key (e.g. `Model(&Member{}).Update(...)`) have nothing to compare with.
+	if member.UUID == "" {
+		return nil
+	}
+
+	var stored Member
+	scope.NewDB().Where("uuid = ?", member.UUID).First(&stored)
+	if stored.UUID == "" {
+		return nil
+	}
+
+	if stored.HashedPassword != member.HashedPassword ||
+		stored.IsActivated != member.IsActivated {
+		member.TokenVersion = stored.TokenVersion + 1
+		if err := scope.SetColumn("TokenVersion", member.TokenVersion); err != nil {
+			return err
+		}
+		return revokeAllSessionsWithDB(scope.NewDB(), member.UUID)
+	}
+	return nil
+}
+
+// AfterDelete는 삭제된 회원의 모든 세션을 폐기하고, 부여된 역할과 프로젝트 참여 기록을 지웁니다.
+func (member *Member) AfterDelete(scope *gorm.Scope) error {
+	if member.UUID == "" {
+		return nil
+	}
+	if err := scope.NewDB().Where(&MemberRole{MemberUUID: member.UUID}).Delete(MemberRole{}).Error; err != nil {
+		return err
+	}
+	if err := scope.NewDB().Where(&ProjectParticipant{MemberUUID: member.UUID}).Delete(ProjectParticipant{}).Error; err != nil {
+		return err
+	}
+	return revokeAllSessionsWithDB(scope.NewDB(), member.UUID)
 }
 
 var memberType = graphql.NewObject(graphql.ObjectConfig{
@@ -47,13 +146,127 @@ var memberType = graphql.NewObject(graphql.ObjectConfig{
 	Fields: graphql.Fields{
 		"uuid":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
 		"loginID":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
-		"email":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
-		"phoneNumber": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
 		"name":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
 		"department":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
-		"studentID":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
 		"isActivated": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
-		"isAdmin":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"generation":  &graphql.Field{Type: graphql.Int, Description: "기수"},
+		"avatarURL":   avatarURLField,
+		"bio":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"githubURL":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"blogURL":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+
+		// Fields below are resolved as null unless the viewer is allowed by the member's privacy settings.
+		"email": privateMemberField("이메일 주소", func(member *Member) (string, string) {
+			return member.Email, member.EmailVisibility
+		}),
+		"phoneNumber": privateMemberField("전화번호", func(member *Member) (string, string) {
+			return member.PhoneNumber, member.PhoneNumberVisibility
+		}),
+		"studentID": privateMemberField("학번", func(member *Member) (string, string) {
+			return member.StudentID, member.StudentIDVisibility
+		}),
+		"privacy": &graphql.Field{
+			Type:        memberPrivacyType,
+			Description: "회원 정보 공개 범위. 본인만 조회할 수 있습니다.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				member := memberFromSource(params.Source)
+				if viewer := viewerFromContext(params.Context); viewer == nil || viewer.UUID != member.UUID {
+					return nil, nil
+				}
+				return member, nil
+			},
+		},
+
+		"withdrawalRequestedAt": &graphql.Field{Type: graphql.DateTime, Description: "회원 탈퇴를 신청한 시각"},
+		"withdrawnAt":           &graphql.Field{Type: graphql.DateTime, Description: "개인 정보가 지워지고 탈퇴 처리된 시각"},
+
+		"isEmailVerified": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"pendingEmail": &graphql.Field{
+			Type:        graphql.String,
+			Description: "변경을 요청했으나 아직 확인되지 않은 이메일 주소. 본인만 조회할 수 있습니다.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				member := memberFromSource(params.Source)
+				if !member.isVisibleTo(viewerFromContext(params.Context), VisibilitySelf) || member.PendingEmail == "" {
+					return nil, nil
+				}
+				return member.PendingEmail, nil
+			},
+		},
+		"isAdmin": &graphql.Field{
+			Type:              graphql.NewNonNull(graphql.Boolean),
+			DeprecationReason: "roles 필드를 사용하세요.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return memberFromSource(params.Source).IsAdmin(), nil
+			},
+		},
+		"roles": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(roleType))),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return getMemberRoles(memberFromSource(params.Source).UUID), nil
+			},
+		},
+		"permissions": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+			Description: "회원이 부여받은 역할의 권한 목록",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return getMemberPermissions(memberFromSource(params.Source).UUID), nil
+			},
+		},
+		"inviteCode": &graphql.Field{
+			Type:        inviteCodeType,
+			Description: "회원이 가입할 때 사용한 초대 코드. members.invite 권한이 필요합니다.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if viewer := params.Context.Value("member"); viewer == nil || !viewer.(*Member).Can(PermissionInviteMembers) {
+					return nil, nil
+				}
+
+				member := memberFromSource(params.Source)
+				if member.InviteCodeID == nil {
+					return nil, nil
+				}
+
+				var code InviteCode
+				database.DB.Where(&InviteCode{ID: *member.InviteCodeID}).First(&code)
+				if code.ID == 0 {
+					return nil, nil
+				}
+				return code, nil
+			},
+		},
+
+		"enrollments": &graphql.Field{
+			Type:        graphql.NewList(graphql.NewNonNull(termEnrollmentType)),
+			Description: "학기별 등록 정보. 본인 또는 terms.manage 권한이 있는 회원만 조회할 수 있습니다.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				member := memberFromSource(params.Source)
+				if viewer := viewerFromContext(params.Context); !member.isVisibleTo(viewer, VisibilitySelf) && !viewer.Can(PermissionManageTerms) {
+					return nil, nil
+				}
+
+				var enrollments []TermEnrollment
+				database.DB.Joins("JOIN terms ON terms.id = term_enrollments.term_id").
+					Where(&TermEnrollment{MemberUUID: member.UUID}).Order("terms.starts_at desc").Find(&enrollments)
+				return enrollments, nil
+			},
+		},
+		"duesPayments": &graphql.Field{
+			Type:        graphql.NewList(graphql.NewNonNull(duesPaymentType)),
+			Description: "회비 납부 기록. 본인 또는 terms.manage 권한이 있는 회원만 조회할 수 있습니다.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				member := memberFromSource(params.Source)
+				if viewer := viewerFromContext(params.Context); !member.isVisibleTo(viewer, VisibilitySelf) && !viewer.Can(PermissionManageTerms) {
+					return nil, nil
+				}
+
+				var payments []DuesPayment
+				database.DB.Where(&DuesPayment{MemberUUID: member.UUID}).Order("paid_at desc").Find(&payments)
+				return payments, nil
+			},
+		},
+
+		"isTOTPEnabled":    &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"isTOTPRequired":   &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"isServiceAccount": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
 	},
 })
 
@@ -76,18 +289,20 @@ var MeQuery = &graphql.Field{
 	Type:        graphql.NewNonNull(memberType),
 	Description: "자신의 회원 정보를 조회합니다.",
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if params.Context.Value("member") == nil {
+		member := requestMember(params.Context, scopeMembersRead)
+		if member == nil {
 			return nil, fmt.Errorf("ERR401")
 		}
-		return params.Context.Value("member").(*Member), nil
+		return member, nil
 	},
 }
 
 var MembersQuery = &graphql.Field{
-	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(memberType))),
-	Description: "회원 목록을 조회합니다. 관리자 권한이 필요합니다.",
+	Type:              graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(memberType))),
+	Description:       "회원 목록을 조회합니다. members.manage 권한이 필요합니다.",
+	DeprecationReason: "memberPage query를 사용하세요.",
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if member := params.Context.Value("member"); member == nil || !member.(*Member).IsAdmin {
+		if member := requestMember(params.Context, scopeMembersRead); member == nil || !member.Can(PermissionManageMembers) {
 			return nil, fmt.Errorf("ERR401")
 		}
 
@@ -100,9 +315,17 @@ var MembersQuery = &graphql.Field{
 // Mutations
 var CreateMemberMutation = &graphql.Field{
 	Type:        memberType,
-	Description: "회원을 추가합니다.",
+	Description: "회원을 추가합니다. 올바른 초대 코드로 가입하면 바로 활성화되며, 그렇지 않으면 모집 중인 가입 신청서가 함께 제출됩니다.",
 	Args: graphql.FieldConfigArgument{
 		"MemberInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(memberInputType)},
+		"inviteCode": &graphql.ArgumentConfig{
+			Type:        graphql.String,
+			Description: "초대 코드. NAGASE_REQUIRE_INVITE_CODE가 설정된 경우 필수입니다.",
+		},
+		"answers": &graphql.ArgumentConfig{
+			Type:        graphql.NewList(graphql.NewNonNull(applicationAnswerInputType)),
+			Description: "가입 신청서 답변. 초대 코드 없이 모집 기간에 가입하는 경우, 가입 신청서가 함께 제출됩니다.",
+		},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
 		memberInput := params.Args["MemberInput"].(map[string]interface{})
@@ -118,38 +341,79 @@ var CreateMemberMutation = &graphql.Field{
 			return nil, fmt.Errorf("MEM001")
 		}
 
-		// Create member model.
-		salt := make([]byte, 32)
-		rand.Read(salt)
+		var inviteCode *InviteCode
+		if code, ok := params.Args["inviteCode"].(string); ok && code != "" {
+			var err error
+			if inviteCode, err = getValidInviteCode(code); err != nil {
+				return nil, err
+			}
+		} else if isInviteCodeRequired {
+			return nil, fmt.Errorf("MEM003")
+		}
 
-		hash := argon2.IDKey([]byte(memberInput["password"].(string)), salt, 1, 8*1024, 4, 32)
+		// Create member model.
 		member := Member{
-			UUID:         uuid.NewV4().String(),
-			LoginID:      memberInput["loginID"].(string),
-			PasswordHash: hash,
-			PasswordSalt: salt,
-			Email:        memberInput["email"].(string),
-			PhoneNumber:  memberInput["phoneNumber"].(string),
-			Name:         memberInput["name"].(string),
-			Department:   memberInput["department"].(string),
-			StudentID:    memberInput["studentID"].(string),
-			IsActivated:  false,
-			IsAdmin:      false,
+			UUID:        uuid.NewV4().String(),
+			LoginID:     memberInput["loginID"].(string),
+			Email:       memberInput["email"].(string),
+			PhoneNumber: memberInput["phoneNumber"].(string),
+			Name:        memberInput["name"].(string),
+			Department:  memberInput["department"].(string),
+			StudentID:   memberInput["studentID"].(string),
+			IsActivated: false,
+		}
+		if inviteCode != nil {
+			// Members invited by a valid code don't need to be activated by admins.
+			member.IsActivated = true
+			member.InviteCodeID = &inviteCode.ID
+			if inviteCode.Department != "" {
+				member.Department = inviteCode.Department
+			}
+		}
+		if err := member.SetPassword(memberInput["password"].(string)); err != nil {
+			return nil, fmt.Errorf("ERR500")
 		}
 
 		// Save record on DB.
-		errs := database.DB.Create(&member).GetErrors()
+		tx := database.DB.Begin()
+		errs := tx.Create(&member).GetErrors()
 		if len(errs) > 0 {
+			tx.Rollback()
 			return nil, fmt.Errorf("failed to create member")
 		}
+		if inviteCode != nil {
+			if err := useInviteCodeWithDB(tx, inviteCode, &member); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			if err := enrollInCurrentTermWithDB(tx, member.UUID); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		} else if form := getOpenApplicationForm(); form != nil {
+			// Members without an invite code are activated when their application is accepted.
+			answers, _ := params.Args["answers"].([]interface{})
+			if err := createApplicationWithDB(tx, form, &member, answers); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+		if err := tx.Commit().Error; err != nil {
+			return nil, err
+		}
 
+		// The member has already been created, so a mail failure must not fail the signup.
+		// The member can request the verification email again.
+		if err := sendEmailVerification(&member, member.Email); err != nil {
+			fmt.Println("Failed to send the verification email to member", member.UUID+":", err)
+		}
 		return member, nil
 	},
 }
 
 var UpdateMemberMutation = &graphql.Field{
 	Type:        memberType,
-	Description: "회원 정보를 수정합니다. 본인만 수정할 수 있습니다.",
+	Description: "회원 정보를 수정합니다. 본인만 수정할 수 있습니다. 이메일 주소는 새 주소로 발송된 확인 링크로 확인한 뒤에 변경됩니다.",
 	Args: graphql.FieldConfigArgument{
 		"MemberInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(memberInputType)},
 	},
@@ -163,8 +427,16 @@ var UpdateMemberMutation = &graphql.Field{
 
 		// Updated fields except password.
 		memberInput := params.Args["MemberInput"].(map[string]interface{})
-		if memberInput["email"] != nil {
-			member.Email = memberInput["email"].(string)
+		pendingEmail := ""
+		if memberInput["email"] != nil && memberInput["email"].(string) != member.Email {
+			var duplicated Member
+			database.DB.Where(&Member{Email: memberInput["email"].(string)}).First(&duplicated)
+			if duplicated.UUID != "" {
+				return nil, fmt.Errorf("MEM001")
+			}
+
+			pendingEmail = memberInput["email"].(string)
+			member.PendingEmail = pendingEmail
 		}
 		if memberInput["phoneNumber"] != nil {
 			member.PhoneNumber = memberInput["phoneNumber"].(string)
@@ -172,14 +444,21 @@ var UpdateMemberMutation = &graphql.Field{
 
 		// Update password (if requested).
 		if memberInput["password"] != nil {
-			hash := argon2.IDKey([]byte(memberInput["password"].(string)), member.PasswordSalt, 1, 8*1024, 4, 32)
-			member.PasswordHash = hash
+			if err := member.SetPassword(memberInput["password"].(string)); err != nil {
+				return nil, fmt.Errorf("ERR500")
+			}
 		}
 
 		errs := database.DB.Save(&member).GetErrors()
 		if len(errs) > 0 {
 			return nil, errs[0]
 		}
+
+		if pendingEmail != "" {
+			if err := sendEmailVerification(member, pendingEmail); err != nil {
+				return nil, err
+			}
+		}
 		return member, nil
 	},
 }
@@ -208,10 +487,9 @@ var UpdateMemberPasswordMutation = &graphql.Field{
 			return nil, fmt.Errorf("TKN001")
 		}
 
-		salt := make([]byte, 32)
-		hash := argon2.IDKey([]byte(input["password"].(string)), salt, 1, 8*1024, 4, 32)
-		member.PasswordHash = hash
-		member.PasswordSalt = salt
+		if err := member.SetPassword(input["password"].(string)); err != nil {
+			return nil, fmt.Errorf("ERR500")
+		}
 		errs := database.DB.Save(&member).GetErrors()
 		if len(errs) > 0 {
 			return nil, errs[0]
@@ -267,69 +545,94 @@ var RequestPasswordResetMutation = &graphql.Field{
 
 var DeleteMemberMutation = &graphql.Field{
 	Type:        memberType,
-	Description: "회원을 삭제합니다. 관리자 권한이 필요합니다.",
+	Description: "회원을 유예 기간 없이 탈퇴 처리합니다. 개인 정보는 지워지고, 작성한 게시물과 댓글은 남습니다. members.manage 권한이 필요합니다.",
 	Args: graphql.FieldConfigArgument{
 		"memberUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if m := params.Context.Value("member"); m == nil || !m.(*Member).IsAdmin {
+		if m := params.Context.Value("member"); m == nil || !m.(*Member).Can(PermissionManageMembers) {
 			return nil, fmt.Errorf("ERR401")
 		}
 
 		var member Member
 		database.DB.Where(&Member{UUID: params.Args["memberUUID"].(string)}).First(&member)
-		if member.UUID == "" {
+		if member.UUID == "" || member.WithdrawnAt != nil {
 			return nil, nil
 		}
 
-		database.DB.Delete(&member)
+		if err := withdrawMember(&member); err != nil {
+			return nil, err
+		}
 		return member, nil
 	},
 }
 
 var ToggleMemberIsActivatedMutation = &graphql.Field{
 	Type:        memberType,
-	Description: "회원을 활성화/비활성화합니다. 관리자 권한이 필요합니다.",
+	Description: "회원을 활성화/비활성화합니다. members.manage 권한이 필요합니다.",
 	Args: graphql.FieldConfigArgument{
 		"memberUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if member := params.Context.Value("member"); member == nil || !member.(*Member).IsAdmin {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageMembers) {
 			return nil, fmt.Errorf("ERR401")
 		}
 
 		member, _ := GetMemberByUUID(params.Args["memberUUID"].(string))
+		if member == nil || member.WithdrawnAt != nil {
+			return nil, fmt.Errorf("ERR400")
+		}
 		member.IsActivated = !member.IsActivated
 		errs := database.DB.Save(&member).GetErrors()
 		if len(errs) > 0 {
 			return nil, errs[0]
 		}
+		if member.IsActivated {
+			if err := enrollInCurrentTermWithDB(database.DB, member.UUID); err != nil {
+				return nil, err
+			}
+		}
 		return member, nil
 	},
 }
 
 var ToggleMemberIsAdminMutation = &graphql.Field{
-	Type:        memberType,
-	Description: "회원을 관리자로 만들거나, 관리자 권한을 해제합니다. 관리자 권한이 필요합니다.",
+	Type:              memberType,
+	Description:       "회원에게 admin 역할을 부여하거나 회수합니다. roles.manage 권한이 필요합니다.",
+	DeprecationReason: "assignRole, unassignRole mutation을 사용하세요.",
 	Args: graphql.FieldConfigArgument{
 		"memberUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if member := params.Context.Value("member"); member == nil || !member.(*Member).IsAdmin {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageRoles) {
 			return nil, fmt.Errorf("ERR401")
 		}
 
-		member, _ := GetMemberByUUID(params.Args["memberUUID"].(string))
-		member.IsAdmin = !member.IsAdmin
-		errs := database.DB.Save(&member).GetErrors()
-		if len(errs) > 0 {
-			return nil, errs[0]
+		member, err := GetMemberByUUID(params.Args["memberUUID"].(string))
+		if err != nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+		var admin Role
+		database.DB.Where(&Role{Name: AdminRoleName}).First(&admin)
+
+		if err := setMemberRole(member, &admin, !member.IsAdmin()); err != nil {
+			return nil, err
 		}
 		return member, nil
 	},
 }
 
 // Common functions
+
+// memberFromSource는 GraphQL resolver의 source로 주어진 회원을 반환합니다.
+func memberFromSource(source interface{}) *Member {
+	if member, ok := source.(*Member); ok {
+		return member
+	}
+	member := source.(Member)
+	return &member
+}
+
 func GetMemberByUUID(uuid string) (*Member, error) {
 	var member Member
 	database.DB.Where(&Member{UUID: uuid}).First(&member)
@@ -338,3 +641,30 @@ func GetMemberByUUID(uuid string) (*Member, error) {
 	}
 	return &member, nil
 }
+
+// migratePasswords는 파라미터 없이 저장된 기존 argon2id 해시(password_hash, password_salt 컬럼)를 PHC 문자열로 옮깁니다.
+// 옮겨진 해시는 다음 로그인 때 새 파라미터로 다시 해시됩니다.
+func migratePasswords() {
+	if !database.DB.Dialect().HasColumn("members", "password_salt") {
+		return
+	}
+
+	type legacyPassword struct {
+		UUID         string
+		PasswordHash []byte
+		PasswordSalt []byte
+	}
+	var legacyPasswords []legacyPassword
+	database.DB.Table("members").Select("uuid, password_hash, password_salt").Scan(&legacyPasswords)
+
+	// Any error aborts the transaction, so the migration is retried on the next start.
+	legacyParams := password.Params{Memory: 8 * 1024, Time: 1, Threads: 4}
+	tx := database.DB.Begin()
+	for _, p := range legacyPasswords {
+		hashed := password.EncodeArgon2id(legacyParams, p.PasswordSalt, p.PasswordHash)
+		tx.Table("members").Where("uuid = ?", p.UUID).UpdateColumn("hashed_password", hashed)
+	}
+	tx.Model(&Member{}).DropColumn("password_hash")
+	tx.Model(&Member{}).DropColumn("password_salt")
+	tx.Commit()
+}
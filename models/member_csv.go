@@ -0,0 +1,372 @@
+package models
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+
+	"nagase/components/database"
+)
+
+// CSV 가져오기 결과
+const (
+	MemberImportCreate    = "CREATE"
+	MemberImportUpdate    = "UPDATE"
+	MemberImportUnchanged = "UNCHANGED"
+	MemberImportError     = "ERROR"
+)
+
+// memberCSVColumn은 CSV로 내보내거나 가져올 수 있는 회원 정보 열입니다.
+type memberCSVColumn struct {
+	name     string
+	get      func(member *Member) string
+	set      func(member *Member, value string) error // nil이면 가져올 수 없는 열입니다.
+	isUnique bool
+}
+
+var memberCSVColumns = []memberCSVColumn{
+	{name: "uuid", get: func(m *Member) string { return m.UUID }},
+	{name: "loginID", get: func(m *Member) string { return m.LoginID }},
+	{name: "name", get: func(m *Member) string { return m.Name }, set: func(m *Member, v string) error {
+		m.Name = v
+		return nil
+	}},
+	{name: "email", isUnique: true, get: func(m *Member) string { return m.Email }, set: func(m *Member, v string) error {
+		if !strings.Contains(v, "@") {
+			return fmt.Errorf("invalid email")
+		}
+		if m.Email != v {
+			m.Email = v
+			m.IsEmailVerified = false
+		}
+		return nil
+	}},
+	{name: "phoneNumber", get: func(m *Member) string { return m.PhoneNumber }, set: func(m *Member, v string) error {
+		m.PhoneNumber = v
+		return nil
+	}},
+	{name: "department", get: func(m *Member) string { return m.Department }, set: func(m *Member, v string) error {
+		m.Department = v
+		return nil
+	}},
+	{name: "studentID", isUnique: true, get: func(m *Member) string { return m.StudentID }, set: func(m *Member, v string) error {
+		m.StudentID = v
+		return nil
+	}},
+	{name: "isActivated", get: func(m *Member) string { return strconv.FormatBool(m.IsActivated) }, set: func(m *Member, v string) error {
+		activated, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid isActivated")
+		}
+		m.IsActivated = activated
+		return nil
+	}},
+	{name: "generation", get: func(m *Member) string {
+		if m.Generation == nil {
+			return ""
+		}
+		return strconv.Itoa(*m.Generation)
+	}, set: func(m *Member, v string) error {
+		if v == "" {
+			m.Generation = nil
+			return nil
+		}
+		generation, err := strconv.Atoi(v)
+		if err != nil || generation < 1 {
+			return fmt.Errorf("invalid generation")
+		}
+		m.Generation = &generation
+		return nil
+	}},
+	{name: "createdAt", get: func(m *Member) string { return m.CreatedAt.Format(time.RFC3339) }},
+}
+
+func getMemberCSVColumn(name string) *memberCSVColumn {
+	for i := range memberCSVColumns {
+		if memberCSVColumns[i].name == name {
+			return &memberCSVColumns[i]
+		}
+	}
+	return nil
+}
+
+type MemberImportChange struct {
+	Field string
+	From  string
+	To    string
+}
+
+type MemberImportRow struct {
+	Line    int
+	LoginID string
+	Action  string
+	Changes []MemberImportChange
+	Error   string
+}
+
+type MemberImportReport struct {
+	IsDryRun     bool
+	IsApplied    bool
+	CreatedCount int
+	UpdatedCount int
+	Rows         []MemberImportRow
+}
+
+var memberImportReportType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "MemberImportReport",
+	Fields: graphql.Fields{
+		"isDryRun":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"isApplied":    &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Description: "변경 사항이 DB에 반영되었는지 여부"},
+		"createdCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"updatedCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"rows": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.NewObject(graphql.ObjectConfig{
+				Name: "MemberImportRow",
+				Fields: graphql.Fields{
+					"line":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+					"loginID": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+					"action":  &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "CREATE, UPDATE, UNCHANGED 또는 ERROR"},
+					"error":   &graphql.Field{Type: graphql.String},
+					"changes": &graphql.Field{
+						Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.NewObject(graphql.ObjectConfig{
+							Name: "MemberImportChange",
+							Fields: graphql.Fields{
+								"field": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+								"from":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+								"to":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+							},
+						})))),
+					},
+				},
+			})))),
+		},
+	},
+})
+
+// Queries
+var ExportMembersQuery = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.String),
+	Description: "회원 목록을 CSV로 내보냅니다. 첫 줄은 열 이름입니다. members.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"fields": &graphql.ArgumentConfig{
+			Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+			Description: "내보낼 열 (uuid, loginID, name, email, phoneNumber, department, studentID, isActivated, generation, createdAt). 지정하지 않으면 모든 열을 내보냅니다.",
+		},
+		"isActivated": &graphql.ArgumentConfig{Type: graphql.Boolean},
+		"department":  &graphql.ArgumentConfig{Type: graphql.String},
+		"termID": &graphql.ArgumentConfig{
+			Type:        graphql.Int,
+			Description: "해당 학기에 등록된 회원만 내보냅니다.",
+		},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageMembers) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		columns := memberCSVColumns
+		if fields, ok := params.Args["fields"].([]interface{}); ok {
+			columns = nil
+			for _, field := range fields {
+				column := getMemberCSVColumn(field.(string))
+				if column == nil {
+					return nil, fmt.Errorf("ERR400")
+				}
+				columns = append(columns, *column)
+			}
+		}
+
+		query := database.DB.Order("members.created_at asc")
+		if isActivated, ok := params.Args["isActivated"].(bool); ok {
+			query = query.Where("members.is_activated = ?", isActivated)
+		}
+		if department, ok := params.Args["department"].(string); ok {
+			query = query.Where("members.department = ?", department)
+		}
+		if termID, ok := params.Args["termID"].(int); ok {
+			query = query.Joins("JOIN term_enrollments ON term_enrollments.member_uuid = members.uuid").
+				Where("term_enrollments.term_id = ?", termID)
+		}
+		var members []Member
+		query.Find(&members)
+
+		var buffer bytes.Buffer
+		writer := csv.NewWriter(&buffer)
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = column.name
+		}
+		writer.Write(header)
+		for i := range members {
+			record := make([]string, len(columns))
+			for j, column := range columns {
+				record[j] = column.get(&members[i])
+			}
+			writer.Write(record)
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return nil, fmt.Errorf("ERR500")
+		}
+		return buffer.String(), nil
+	},
+}
+
+// Mutations
+var ImportMembersMutation = &graphql.Field{
+	Type: graphql.NewNonNull(memberImportReportType),
+	Description: "CSV로 회원을 추가하거나 수정합니다. loginID 열로 회원을 찾으며, 없는 회원은 새로 추가합니다. " +
+		"모든 행은 하나의 트랜잭션으로 처리되어, 오류가 있는 행이 하나라도 있으면 아무것도 반영되지 않습니다. members.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"csv": &graphql.ArgumentConfig{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "첫 줄은 열 이름입니다. loginID 열이 필요하며, 새 회원을 추가하려면 name, email 열도 필요합니다.",
+		},
+		"dryRun": &graphql.ArgumentConfig{
+			Type:         graphql.Boolean,
+			DefaultValue: true,
+			Description:  "true이면 반영하지 않고 변경 사항만 확인합니다.",
+		},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageMembers) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		records, err := csv.NewReader(strings.NewReader(params.Args["csv"].(string))).ReadAll()
+		if err != nil || len(records) == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		// Validate the header.
+		header := records[0]
+		loginIDIndex := -1
+		for i, name := range header {
+			header[i] = strings.TrimSpace(name)
+			if header[i] == "loginID" {
+				loginIDIndex = i
+			} else if column := getMemberCSVColumn(header[i]); column == nil || column.set == nil {
+				return nil, fmt.Errorf("ERR400")
+			}
+		}
+		if loginIDIndex < 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		report := MemberImportReport{IsDryRun: params.Args["dryRun"].(bool), Rows: []MemberImportRow{}}
+		var created []*Member
+
+		tx := database.DB.Begin()
+		for i, record := range records[1:] {
+			row, member := importMemberRecord(tx, header, record, loginIDIndex)
+			row.Line = i + 2
+			report.Rows = append(report.Rows, row)
+
+			switch row.Action {
+			case MemberImportCreate:
+				report.CreatedCount++
+				created = append(created, member)
+			case MemberImportUpdate:
+				report.UpdatedCount++
+			}
+		}
+
+		for _, row := range report.Rows {
+			if row.Action == MemberImportError {
+				tx.Rollback()
+				return report, nil
+			}
+		}
+		if report.IsDryRun {
+			tx.Rollback()
+			return report, nil
+		}
+		if err := tx.Commit().Error; err != nil {
+			return nil, err
+		}
+		report.IsApplied = true
+
+		for _, member := range created {
+			sendEmailVerification(member, member.Email)
+		}
+		return report, nil
+	},
+}
+
+// Common functions
+
+// importMemberRecord는 CSV의 한 행을 트랜잭션에 반영하고, 변경 사항을 반환합니다.
+func importMemberRecord(tx *gorm.DB, header []string, record []string, loginIDIndex int) (MemberImportRow, *Member) {
+	row := MemberImportRow{LoginID: strings.TrimSpace(record[loginIDIndex]), Changes: []MemberImportChange{}}
+	fail := func(message string) (MemberImportRow, *Member) {
+		row.Action = MemberImportError
+		row.Error = message
+		return row, nil
+	}
+	if row.LoginID == "" {
+		return fail("loginID is empty")
+	}
+
+	var member Member
+	tx.Where(&Member{LoginID: row.LoginID}).First(&member)
+	isNew := member.UUID == ""
+	if isNew {
+		member = Member{UUID: uuid.NewV4().String(), LoginID: row.LoginID}
+	}
+
+	for i, name := range header {
+		if i == loginIDIndex {
+			continue
+		}
+		column := getMemberCSVColumn(name)
+		value := strings.TrimSpace(record[i])
+
+		before := column.get(&member)
+		if err := column.set(&member, value); err != nil {
+			return fail(err.Error())
+		}
+		if after := column.get(&member); before != after {
+			row.Changes = append(row.Changes, MemberImportChange{Field: name, From: before, To: after})
+
+			// Check unique columns before saving, as a failed statement aborts the whole transaction.
+			// Empty values are checked as well, since they also collide on the unique index.
+			if column.isUnique {
+				var duplicated Member
+				tx.Where(fmt.Sprintf("%s = ? AND uuid <> ?", gorm.ToDBName(name)), after, member.UUID).First(&duplicated)
+				if duplicated.UUID != "" {
+					return fail(name + " is duplicated")
+				}
+			}
+		}
+	}
+
+	switch {
+	case isNew && (member.Name == "" || member.Email == "" || member.StudentID == ""):
+		return fail("name, email and studentID are required for new members")
+	case isNew:
+		row.Action = MemberImportCreate
+	case len(row.Changes) > 0:
+		row.Action = MemberImportUpdate
+	default:
+		row.Action = MemberImportUnchanged
+		return row, &member
+	}
+
+	var errs []error
+	if isNew {
+		errs = tx.Create(&member).GetErrors()
+	} else {
+		errs = tx.Save(&member).GetErrors()
+	}
+	if len(errs) > 0 {
+		return fail(errs[0].Error())
+	}
+	return row, &member
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/jinzhu/gorm"
+
+	"nagase/components/database"
+)
+
+const (
+	memberPageDefaultCount = 20
+	memberPageMaxCount     = 100
+)
+
+// 회원 목록 정렬 기준
+var memberSortOrders = map[string]string{
+	"CREATED_AT_DESC": "members.created_at desc",
+	"CREATED_AT_ASC":  "members.created_at asc",
+	"NAME_ASC":        "members.name asc, members.login_id asc",
+	"LOGIN_ID_ASC":    "members.login_id asc",
+	"STUDENT_ID_ASC":  "members.student_id asc",
+}
+
+type MemberPage struct {
+	Members    []Member
+	TotalCount int
+	PageInfo   PageInfo
+}
+
+var memberPageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "MemberPage",
+	Fields: graphql.Fields{
+		"members":    &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(memberType)))},
+		"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"pageInfo":   &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+	},
+})
+
+var memberDirectoryPageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "MemberDirectoryPage",
+	Fields: graphql.Fields{
+		"members":    &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(memberProfileType)))},
+		"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"pageInfo":   &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+	},
+})
+
+var memberSortArgument = &graphql.ArgumentConfig{
+	Type:         graphql.String,
+	DefaultValue: "CREATED_AT_DESC",
+	Description:  "CREATED_AT_DESC, CREATED_AT_ASC, NAME_ASC, LOGIN_ID_ASC 또는 STUDENT_ID_ASC",
+}
+
+// Queries
+var MemberPageQuery = &graphql.Field{
+	Type:        memberPageType,
+	Description: "회원 목록을 검색합니다. members.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"query": &graphql.ArgumentConfig{
+			Type:        graphql.String,
+			Description: "이름, 아이디 또는 학번에 포함된 문자열",
+		},
+		"isActivated": &graphql.ArgumentConfig{Type: graphql.Boolean},
+		"isAdmin":     &graphql.ArgumentConfig{Type: graphql.Boolean},
+		"department":  &graphql.ArgumentConfig{Type: graphql.String},
+		"termID": &graphql.ArgumentConfig{
+			Type:        graphql.Int,
+			Description: "해당 학기에 등록된 회원",
+		},
+		"sort":  memberSortArgument,
+		"page":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+		"count": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: memberPageDefaultCount},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := requestMember(params.Context, scopeMembersRead); member == nil || !member.Can(PermissionManageMembers) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		query := database.DB.Model(&Member{})
+		if search, ok := params.Args["query"].(string); ok && strings.TrimSpace(search) != "" {
+			pattern := "%" + escapeLikePattern(strings.TrimSpace(search)) + "%"
+			query = query.Where("members.name ILIKE ? OR members.login_id ILIKE ? OR members.student_id ILIKE ?", pattern, pattern, pattern)
+		}
+		if isActivated, ok := params.Args["isActivated"].(bool); ok {
+			query = query.Where("members.is_activated = ?", isActivated)
+		}
+		if isAdmin, ok := params.Args["isAdmin"].(bool); ok {
+			admins := database.DB.Table("member_roles").Select("member_roles.member_uuid").
+				Joins("JOIN roles ON roles.id = member_roles.role_id").Where("roles.name = ?", AdminRoleName).QueryExpr()
+			if isAdmin {
+				query = query.Where("members.uuid IN (?)", admins)
+			} else {
+				query = query.Where("members.uuid NOT IN (?)", admins)
+			}
+		}
+		if department, ok := params.Args["department"].(string); ok {
+			query = query.Where("members.department = ?", department)
+		}
+		if termID, ok := params.Args["termID"].(int); ok {
+			query = query.Joins("JOIN term_enrollments ON term_enrollments.member_uuid = members.uuid").
+				Where("term_enrollments.term_id = ?", termID)
+		}
+
+		var page MemberPage
+		if err := findMemberPage(query, &params, &page); err != nil {
+			return nil, err
+		}
+		return page, nil
+	},
+}
+
+var MemberDirectoryQuery = &graphql.Field{
+	Type:        memberDirectoryPageType,
+	Description: "활성화된 회원의 프로필 목록을 검색합니다. 활성화된 회원만 조회할 수 있습니다.",
+	Args: graphql.FieldConfigArgument{
+		"query": &graphql.ArgumentConfig{
+			Type:        graphql.String,
+			Description: "이름 또는 아이디에 포함된 문자열",
+		},
+		"department": &graphql.ArgumentConfig{Type: graphql.String},
+		"generation": &graphql.ArgumentConfig{Type: graphql.Int},
+		"sort":       memberSortArgument,
+		"page":       &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+		"count":      &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: memberPageDefaultCount},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := requestMember(params.Context, scopeMembersRead); member == nil || !member.IsActivated {
+			return nil, fmt.Errorf("ERR401")
+		}
+		if params.Args["sort"] == "STUDENT_ID_ASC" {
+			// Student IDs are private, so members can't be sorted by them.
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		query := database.DB.Model(&Member{}).Where("members.is_activated = ?", true)
+		if search, ok := params.Args["query"].(string); ok && strings.TrimSpace(search) != "" {
+			pattern := "%" + escapeLikePattern(strings.TrimSpace(search)) + "%"
+			query = query.Where("members.name ILIKE ? OR members.login_id ILIKE ?", pattern, pattern)
+		}
+		if department, ok := params.Args["department"].(string); ok {
+			query = query.Where("members.department = ?", department)
+		}
+		if generation, ok := params.Args["generation"].(int); ok {
+			query = query.Where("members.generation = ?", generation)
+		}
+
+		var page MemberPage
+		if err := findMemberPage(query, &params, &page); err != nil {
+			return nil, err
+		}
+		return page, nil
+	},
+}
+
+// Common functions
+
+// findMemberPage는 검색 조건에 맞는 회원 중 page 번째 페이지를 가져옵니다.
+func findMemberPage(query *gorm.DB, params *graphql.ResolveParams, page *MemberPage) error {
+	order, ok := memberSortOrders[params.Args["sort"].(string)]
+	pageNumber, count := params.Args["page"].(int), params.Args["count"].(int)
+	if !ok || pageNumber < 1 || count < 1 || count > memberPageMaxCount {
+		return fmt.Errorf("ERR400")
+	}
+
+	query.Count(&page.TotalCount)
+	page.Members = []Member{}
+	query.Order(order).Offset((pageNumber - 1) * count).Limit(count).Find(&page.Members)
+	page.PageInfo = PageInfo{
+		HasPrevious: pageNumber > 1,
+		HasNext:     pageNumber*count < page.TotalCount,
+	}
+	return nil
+}
+
+func escapeLikePattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,404 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+
+	"nagase/components/database"
+)
+
+// MemberGroup은 스터디나 임원진처럼 게시판 접근 권한을 함께 받는 회원의 모임입니다.
+type MemberGroup struct {
+	ID int
+
+	Name        string `gorm:"type:varchar(40);UNIQUE_INDEX"`
+	Description string `gorm:"type:varchar(255)"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// MemberGroupMembership은 그룹에 속한 회원입니다. 그룹 관리자는 그룹 정보를 수정하고 회원을 추가하거나 뺄 수 있습니다.
+type MemberGroupMembership struct {
+	GroupID    int    `gorm:"PRIMARY_KEY;auto_increment:false"`
+	MemberUUID string `gorm:"type:varchar(40);PRIMARY_KEY"`
+	IsAdmin    bool   `gorm:"default:false"`
+
+	CreatedAt time.Time
+}
+
+// BoardGroupAccess는 게시판에서 그룹의 회원에게 추가로 허용하는 읽기, 쓰기, 댓글 권한입니다.
+type BoardGroupAccess struct {
+	BoardID    int  `gorm:"PRIMARY_KEY;auto_increment:false"`
+	GroupID    int  `gorm:"PRIMARY_KEY;auto_increment:false"`
+	CanRead    bool `gorm:"default:false"`
+	CanWrite   bool `gorm:"default:false"`
+	CanComment bool `gorm:"default:false"`
+}
+
+var memberGroupType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "MemberGroup",
+	Description: "회원 그룹",
+	Fields: graphql.Fields{
+		"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"name":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"description": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"members": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(memberGroupMembershipType))),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				var memberships []MemberGroupMembership
+				database.DB.Where(&MemberGroupMembership{GroupID: params.Source.(MemberGroup).ID}).Order("created_at asc").Find(&memberships)
+				return memberships, nil
+			},
+		},
+		"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+	},
+})
+
+var memberGroupMembershipType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "MemberGroupMembership",
+	Fields: graphql.Fields{
+		"member": &graphql.Field{
+			Type: graphql.NewNonNull(publicMemberType),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return getPublicMember(params.Source.(MemberGroupMembership).MemberUUID), nil
+			},
+		},
+		"isAdmin":   &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Description: "그룹 관리자 여부"},
+		"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+	},
+})
+
+var boardGroupAccessType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "BoardGroupAccess",
+	Fields: graphql.Fields{
+		"group": &graphql.Field{
+			Type: memberGroupType,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				var group MemberGroup
+				database.DB.Where(&MemberGroup{ID: params.Source.(BoardGroupAccess).GroupID}).First(&group)
+				if group.ID == 0 {
+					return nil, nil
+				}
+				return group, nil
+			},
+		},
+		"canRead":    &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"canWrite":   &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"canComment": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+	},
+})
+
+var memberGroupInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "MemberGroupInput",
+	Description: "회원 그룹 추가/수정 InputObject",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"description": &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+// Queries
+var MemberGroupsQuery = &graphql.Field{
+	Type:        graphql.NewList(graphql.NewNonNull(memberGroupType)),
+	Description: "회원 그룹 목록을 조회합니다. 활성화된 회원만 조회할 수 있습니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).IsActivated {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var groups []MemberGroup
+		database.DB.Order("name asc").Find(&groups)
+		return groups, nil
+	},
+}
+
+// Mutations
+var CreateMemberGroupMutation = &graphql.Field{
+	Type:        memberGroupType,
+	Description: "회원 그룹을 추가합니다. 그룹을 만든 회원은 그룹 관리자가 됩니다. groups.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"MemberGroupInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(memberGroupInputType)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageGroups) {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		var group MemberGroup
+		if err := setMemberGroupInput(&group, params.Args["MemberGroupInput"].(map[string]interface{})); err != nil {
+			return nil, err
+		}
+
+		tx := database.DB.Begin()
+		if err := tx.Create(&group).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := tx.Create(&MemberGroupMembership{GroupID: group.ID, MemberUUID: member.UUID, IsAdmin: true}).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return nil, err
+		}
+		return group, nil
+	},
+}
+
+var UpdateMemberGroupMutation = &graphql.Field{
+	Type:        memberGroupType,
+	Description: "회원 그룹을 수정합니다. groups.manage 권한이 있거나 그룹 관리자여야 합니다.",
+	Args: graphql.FieldConfigArgument{
+		"groupID":          &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"MemberGroupInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(memberGroupInputType)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		group, err := getManagedMemberGroup(params)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := setMemberGroupInput(group, params.Args["MemberGroupInput"].(map[string]interface{})); err != nil {
+			return nil, err
+		}
+		errs := database.DB.Save(group).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return *group, nil
+	},
+}
+
+var DeleteMemberGroupMutation = &graphql.Field{
+	Type:        memberGroupType,
+	Description: "회원 그룹을 삭제합니다. 그룹에 허용된 게시판 권한도 함께 삭제됩니다. groups.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"groupID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageGroups) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var group MemberGroup
+		database.DB.Where(&MemberGroup{ID: params.Args["groupID"].(int)}).First(&group)
+		if group.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		tx := database.DB.Begin()
+		for _, model := range []interface{}{MemberGroupMembership{}, BoardGroupAccess{}} {
+			if err := tx.Where("group_id = ?", group.ID).Delete(model).Error; err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+		if err := tx.Delete(&group).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return nil, err
+		}
+		return group, nil
+	},
+}
+
+var AddMemberGroupMemberMutation = &graphql.Field{
+	Type:        memberGroupType,
+	Description: "회원을 그룹에 추가하거나, 이미 속한 회원의 그룹 관리자 여부를 바꿉니다. groups.manage 권한이 있거나 그룹 관리자여야 합니다.",
+	Args: graphql.FieldConfigArgument{
+		"groupID":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"memberUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+		"isAdmin":    &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		group, err := getManagedMemberGroup(params)
+		if err != nil {
+			return nil, err
+		}
+		member, err := GetMemberByUUID(params.Args["memberUUID"].(string))
+		if err != nil || member.WithdrawnAt != nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		membership := MemberGroupMembership{GroupID: group.ID, MemberUUID: member.UUID}
+		database.DB.Where(&membership).First(&membership)
+		membership.IsAdmin = params.Args["isAdmin"].(bool)
+		errs := database.DB.Save(&membership).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return *group, nil
+	},
+}
+
+var RemoveMemberGroupMemberMutation = &graphql.Field{
+	Type:        memberGroupType,
+	Description: "회원을 그룹에서 뺍니다. groups.manage 권한이 있거나 그룹 관리자여야 합니다. 본인은 언제든 그룹에서 나갈 수 있습니다.",
+	Args: graphql.FieldConfigArgument{
+		"groupID":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"memberUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		var group MemberGroup
+		database.DB.Where(&MemberGroup{ID: params.Args["groupID"].(int)}).First(&group)
+		if group.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+		memberUUID := params.Args["memberUUID"].(string)
+		if memberUUID != member.UUID && !member.canManageMemberGroup(group.ID) {
+			return nil, fmt.Errorf("ERR403")
+		}
+
+		errs := database.DB.Where(&MemberGroupMembership{GroupID: group.ID, MemberUUID: memberUUID}).Delete(MemberGroupMembership{}).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return group, nil
+	},
+}
+
+var SetBoardGroupAccessMutation = &graphql.Field{
+	Type:        boardType,
+	Description: "게시판에서 그룹의 회원에게 허용할 읽기, 쓰기, 댓글 권한을 정합니다. 모두 false이면 그룹의 권한을 지웁니다. boards.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"boardID":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"groupID":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"canRead":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Boolean)},
+		"canWrite":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Boolean)},
+		"canComment": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Boolean)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageBoards) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var board Board
+		database.DB.Where(&Board{ID: params.Args["boardID"].(int)}).First(&board)
+		var group MemberGroup
+		database.DB.Where(&MemberGroup{ID: params.Args["groupID"].(int)}).First(&group)
+		if board.ID == 0 || group.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		access := BoardGroupAccess{
+			BoardID:    board.ID,
+			GroupID:    group.ID,
+			CanRead:    params.Args["canRead"].(bool),
+			CanWrite:   params.Args["canWrite"].(bool),
+			CanComment: params.Args["canComment"].(bool),
+		}
+		var errs []error
+		if !access.CanRead && !access.CanWrite && !access.CanComment {
+			errs = database.DB.Where(&BoardGroupAccess{BoardID: board.ID, GroupID: group.ID}).Delete(BoardGroupAccess{}).GetErrors()
+		} else {
+			errs = database.DB.Save(&access).GetErrors()
+		}
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return board, nil
+	},
+}
+
+// Common functions
+
+// canManageMemberGroup은 회원이 groups.manage 권한이 있거나 그룹 관리자인지 확인합니다.
+func (member *Member) canManageMemberGroup(groupID int) bool {
+	if member == nil {
+		return false
+	} else if member.Can(PermissionManageGroups) {
+		return true
+	}
+
+	var membership MemberGroupMembership
+	database.DB.Where(&MemberGroupMembership{GroupID: groupID, MemberUUID: member.UUID}).First(&membership)
+	return membership.GroupID != 0 && membership.IsAdmin
+}
+
+// hasBoardGroupAccess는 회원이 속한 그룹 중 하나라도 게시판에서 column(can_read, can_write 또는 can_comment) 권한을 받았는지 확인합니다.
+func (member *Member) hasBoardGroupAccess(boardID int, column string) bool {
+	if member == nil {
+		return false
+	}
+
+	var count int
+	database.DB.Model(&BoardGroupAccess{}).
+		Joins("JOIN member_group_memberships ON member_group_memberships.group_id = board_group_accesses.group_id").
+		Where("board_group_accesses.board_id = ? AND member_group_memberships.member_uuid = ?", boardID, member.UUID).
+		Where("board_group_accesses."+column+" = ?", true).Count(&count)
+	return count > 0
+}
+
+// getManagedMemberGroup은 groupID 인자의 그룹을 가져오고, 요청한 회원이 그룹을 관리할 수 있는지 확인합니다.
+func getManagedMemberGroup(params graphql.ResolveParams) (*MemberGroup, error) {
+	if params.Context.Value("member") == nil {
+		return nil, fmt.Errorf("ERR401")
+	}
+	member := params.Context.Value("member").(*Member)
+
+	var group MemberGroup
+	database.DB.Where(&MemberGroup{ID: params.Args["groupID"].(int)}).First(&group)
+	if group.ID == 0 {
+		return nil, fmt.Errorf("ERR400")
+	} else if !member.canManageMemberGroup(group.ID) {
+		return nil, fmt.Errorf("ERR403")
+	}
+	return &group, nil
+}
+
+func setMemberGroupInput(group *MemberGroup, input map[string]interface{}) error {
+	if input["name"] != nil {
+		group.Name = strings.TrimSpace(input["name"].(string))
+	}
+	if input["description"] != nil {
+		group.Description = input["description"].(string)
+	}
+	if group.Name == "" {
+		return fmt.Errorf("ERR400")
+	}
+
+	var duplicated MemberGroup
+	database.DB.Where(&MemberGroup{Name: group.Name}).First(&duplicated)
+	if duplicated.ID != 0 && duplicated.ID != group.ID {
+		return fmt.Errorf("GRP000")
+	}
+	return nil
+}
+
+func init() {
+	boardType.AddFieldConfig("groupAccesses", &graphql.Field{
+		Type:        graphql.NewList(graphql.NewNonNull(boardGroupAccessType)),
+		Description: "게시판에서 그룹에 허용된 권한. boards.manage 권한이 있는 회원만 조회할 수 있습니다.",
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageBoards) {
+				return nil, nil
+			}
+
+			var accesses []BoardGroupAccess
+			database.DB.Where(&BoardGroupAccess{BoardID: params.Source.(Board).ID}).Order("group_id asc").Find(&accesses)
+			return accesses, nil
+		},
+	})
+	memberType.AddFieldConfig("groups", &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(memberGroupType))),
+		Description: "회원이 속한 그룹",
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			groups := []MemberGroup{}
+			database.DB.Joins("JOIN member_group_memberships ON member_group_memberships.group_id = member_groups.id").
+				Where("member_group_memberships.member_uuid = ?", memberFromSource(params.Source).UUID).Order("member_groups.name asc").Find(&groups)
+			return groups, nil
+		},
+	})
+}
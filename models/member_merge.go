@@ -0,0 +1,292 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/jinzhu/gorm"
+
+	"nagase/components/database"
+)
+
+// 기존 홈페이지(Yuzuki)에서 옮겨온 회원의 UUID 접두사. scripts/20181225_migration.go 참고.
+const legacyMemberUUIDPrefix = "00000000-0000-0000-0000-"
+
+// 기존 계정 연결 요청 상태
+const (
+	LegacyAccountClaimStatusPending  = "PENDING"
+	LegacyAccountClaimStatusApproved = "APPROVED"
+	LegacyAccountClaimStatusRejected = "REJECTED"
+)
+
+// 회원을 병합할 때 옮길 기록. keyColumn이 있는 경우, 병합될 회원에게 같은 key의 기록이 이미 있으면 옮기지 않고 지웁니다.
+var mergedMemberTables = []struct {
+	table        string
+	memberColumn string
+	keyColumn    string
+}{
+	{"posts", "author_uuid", ""},
+	{"comments", "author_uuid", ""},
+	{"vote_selections", "member_uuid", "vote_id"},
+	{"board_subscriptions", "member_uuid", "board_id"},
+	{"post_subscriptions", "member_uuid", "post_id"},
+	{"project_participants", "member_uuid", "project_id"},
+	{"board_moderators", "member_uuid", "board_id"},
+	{"member_group_memberships", "member_uuid", "group_id"},
+	{"term_enrollments", "member_uuid", "term_id"},
+	{"dues_payments", "member_uuid", ""},
+	{"uploaded_files", "member_uuid", ""},
+}
+
+// LegacyAccountClaim은 회원이 기존 홈페이지 계정을 자신의 계정으로 가져오려는 요청입니다.
+// 기존 비밀번호를 확인한 경우 바로 승인되며, 그렇지 않으면 members.manage 권한이 있는 회원의 승인이 필요합니다.
+type LegacyAccountClaim struct {
+	ID int
+
+	LegacyMemberUUID string  `gorm:"type:varchar(40);INDEX"`
+	MemberUUID       string  `gorm:"type:varchar(40);INDEX"`
+	Status           string  `gorm:"type:varchar(20)"`
+	Note             string  `gorm:"type:text"`
+	DecidedByUUID    *string `gorm:"type:varchar(40)"`
+	DecidedAt        *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// isLegacy는 기존 홈페이지에서 옮겨온 뒤 아직 병합되거나 탈퇴하지 않은 회원인지 확인합니다.
+func (member *Member) isLegacy() bool {
+	return strings.HasPrefix(member.UUID, legacyMemberUUIDPrefix) && member.WithdrawnAt == nil
+}
+
+var legacyAccountClaimType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "LegacyAccountClaim",
+	Description: "기존 홈페이지 계정 연결 요청",
+	Fields: graphql.Fields{
+		"id": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"legacyMember": &graphql.Field{
+			Type: memberType,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				member, _ := GetMemberByUUID(params.Source.(LegacyAccountClaim).LegacyMemberUUID)
+				return member, nil
+			},
+		},
+		"member": &graphql.Field{
+			Type:        memberType,
+			Description: "기존 계정을 가져오려는 회원",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				member, _ := GetMemberByUUID(params.Source.(LegacyAccountClaim).MemberUUID)
+				return member, nil
+			},
+		},
+		"status":    &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "PENDING, APPROVED 또는 REJECTED"},
+		"note":      &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "본인 확인을 위해 회원이 남긴 설명"},
+		"decidedAt": &graphql.Field{Type: graphql.DateTime},
+		"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+	},
+})
+
+// Queries
+var LegacyAccountClaimsQuery = &graphql.Field{
+	Type:        graphql.NewList(graphql.NewNonNull(legacyAccountClaimType)),
+	Description: "기존 홈페이지 계정 연결 요청 목록을 조회합니다. members.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"status": &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: LegacyAccountClaimStatusPending},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageMembers) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var claims []LegacyAccountClaim
+		database.DB.Where(&LegacyAccountClaim{Status: params.Args["status"].(string)}).Order("id asc").Find(&claims)
+		return claims, nil
+	},
+}
+
+// Mutations
+var ClaimLegacyAccountMutation = &graphql.Field{
+	Type:        legacyAccountClaimType,
+	Description: "기존 홈페이지 계정을 자신의 계정으로 가져옵니다. 기존 비밀번호가 맞으면 바로 병합되며, 비밀번호 없이 요청하면 관리자가 승인한 뒤에 병합됩니다.",
+	Args: graphql.FieldConfigArgument{
+		"loginID":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String), Description: "기존 홈페이지의 아이디"},
+		"password": &graphql.ArgumentConfig{Type: graphql.String, Description: "기존 홈페이지의 비밀번호"},
+		"note":     &graphql.ArgumentConfig{Type: graphql.String, Description: "비밀번호를 모르는 경우, 본인임을 확인할 수 있는 설명"},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member, _ := GetMemberByUUID(params.Context.Value("member").(*Member).UUID)
+		ipAddress, _ := params.Context.Value("ipAddress").(string)
+
+		var legacy Member
+		database.DB.Where(&Member{LoginID: params.Args["loginID"].(string)}).First(&legacy)
+		if legacy.UUID == "" || !legacy.isLegacy() || member.isLegacy() {
+			return nil, fmt.Errorf("MEM004")
+		}
+
+		claim := LegacyAccountClaim{LegacyMemberUUID: legacy.UUID, MemberUUID: member.UUID, Status: LegacyAccountClaimStatusPending}
+		if note, ok := params.Args["note"].(string); ok {
+			claim.Note = strings.TrimSpace(note)
+		}
+
+		if plain, ok := params.Args["password"].(string); ok {
+			// Guessing old passwords is throttled the same way as logging in.
+			if err := checkMemberLoginAllowed(&legacy, ipAddress); err != nil {
+				return nil, err
+			}
+			if legacy.HashedPassword == "" || !legacy.ValidatePassword(plain) {
+				recordMemberLoginFailure(&legacy, ipAddress)
+				return nil, fmt.Errorf("TKN000")
+			}
+
+			now := time.Now()
+			claim.Status = LegacyAccountClaimStatusApproved
+			claim.DecidedAt = &now
+
+			tx := database.DB.Begin()
+			if err := tx.Create(&claim).Error; err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			if err := mergeMemberWithDB(tx, &legacy, member); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			if err := tx.Commit().Error; err != nil {
+				return nil, err
+			}
+			removeOrphanedDataExportFiles()
+			return claim, nil
+		}
+
+		var pending LegacyAccountClaim
+		database.DB.Where(&LegacyAccountClaim{LegacyMemberUUID: legacy.UUID, MemberUUID: member.UUID, Status: LegacyAccountClaimStatusPending}).First(&pending)
+		if pending.ID != 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		errs := database.DB.Create(&claim).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return claim, nil
+	},
+}
+
+var DecideLegacyAccountClaimMutation = &graphql.Field{
+	Type:        legacyAccountClaimType,
+	Description: "기존 홈페이지 계정 연결 요청을 승인하거나 거절합니다. 승인하면 기존 계정이 요청한 회원에 병합됩니다. members.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"claimID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"approve": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Boolean)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageMembers) {
+			return nil, fmt.Errorf("ERR401")
+		}
+		decider := params.Context.Value("member").(*Member)
+
+		var claim LegacyAccountClaim
+		database.DB.Where(&LegacyAccountClaim{ID: params.Args["claimID"].(int)}).First(&claim)
+		if claim.ID == 0 || claim.Status != LegacyAccountClaimStatusPending {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		now := time.Now()
+		claim.Status = LegacyAccountClaimStatusRejected
+		claim.DecidedByUUID = &decider.UUID
+		claim.DecidedAt = &now
+
+		tx := database.DB.Begin()
+		if params.Args["approve"].(bool) {
+			legacy, err := GetMemberByUUID(claim.LegacyMemberUUID)
+			if err != nil || !legacy.isLegacy() {
+				tx.Rollback()
+				return nil, fmt.Errorf("MEM004")
+			}
+			member, err := GetMemberByUUID(claim.MemberUUID)
+			if err != nil || member.WithdrawnAt != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("MEM004")
+			}
+
+			claim.Status = LegacyAccountClaimStatusApproved
+			if err := mergeMemberWithDB(tx, legacy, member); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+		if err := tx.Save(&claim).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return nil, err
+		}
+		removeOrphanedDataExportFiles()
+		return claim, nil
+	},
+}
+
+var MergeMembersMutation = &graphql.Field{
+	Type:        memberType,
+	Description: "중복된 회원을 병합합니다. source 회원의 게시물, 댓글, 투표, 구독 정보 등을 target 회원으로 옮기고 source 회원은 탈퇴 처리합니다. members.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"sourceUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+		"targetUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageMembers) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		source, err := GetMemberByUUID(params.Args["sourceUUID"].(string))
+		if err != nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+		target, err := GetMemberByUUID(params.Args["targetUUID"].(string))
+		if err != nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+		if source.UUID == target.UUID || source.WithdrawnAt != nil || target.WithdrawnAt != nil {
+			return nil, fmt.Errorf("MEM004")
+		}
+
+		tx := database.DB.Begin()
+		if err := mergeMemberWithDB(tx, source, target); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return nil, err
+		}
+		removeOrphanedDataExportFiles()
+		return target, nil
+	},
+}
+
+// Common functions
+
+// mergeMemberWithDB는 source 회원의 기록을 target 회원으로 옮기고, source 회원을 탈퇴 처리합니다.
+func mergeMemberWithDB(db *gorm.DB, source *Member, target *Member) error {
+	for _, t := range mergedMemberTables {
+		if t.keyColumn != "" {
+			query := fmt.Sprintf("DELETE FROM %s WHERE %s = ? AND %s IN (SELECT %s FROM %s WHERE %s = ?)",
+				t.table, t.memberColumn, t.keyColumn, t.keyColumn, t.table, t.memberColumn)
+			if err := db.Exec(query, source.UUID, target.UUID).Error; err != nil {
+				return err
+			}
+		}
+		query := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", t.table, t.memberColumn, t.memberColumn)
+		if err := db.Exec(query, target.UUID, source.UUID).Error; err != nil {
+			return err
+		}
+	}
+
+	source.MergedIntoUUID = target.UUID
+	return anonymizeMemberWithDB(db, source)
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/graphql-go/graphql"
+
+	"nagase/components/database"
+)
+
+// 회원 정보 공개 범위
+const (
+	VisibilityPublic  = "PUBLIC"  // 로그인하지 않은 사용자를 포함한 모든 사용자
+	VisibilityMembers = "MEMBERS" // 활성화된 회원
+	VisibilityAdmins  = "ADMINS"  // members.manage 권한이 있는 회원
+	VisibilitySelf    = "SELF"    // 본인
+)
+
+// isVisibleTo는 공개 범위가 visibility인 회원 정보를 viewer가 볼 수 있는지 확인합니다. 본인은 항상 볼 수 있습니다.
+func (member *Member) isVisibleTo(viewer *Member, visibility string) bool {
+	if viewer != nil && viewer.UUID == member.UUID {
+		return true
+	}
+
+	switch visibility {
+	case VisibilityPublic:
+		return true
+	case VisibilityMembers:
+		return viewer != nil && viewer.IsActivated
+	case VisibilitySelf:
+		return false
+	default:
+		return viewer.Can(PermissionManageMembers)
+	}
+}
+
+// privateMemberField는 공개 범위에 따라 회원 정보를 보여주는 필드를 만듭니다. 볼 수 없는 경우 null을 반환합니다.
+func privateMemberField(description string, value func(member *Member) (string, string)) *graphql.Field {
+	return &graphql.Field{
+		Type:        graphql.String,
+		Description: description,
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			member := memberFromSource(params.Source)
+			field, visibility := value(member)
+			if !member.isVisibleTo(viewerFromContext(params.Context), visibility) {
+				return nil, nil
+			}
+			return field, nil
+		},
+	}
+}
+
+// viewerFromContext는 회원 정보를 조회하는 회원을 반환합니다.
+func viewerFromContext(ctx context.Context) *Member {
+	return requestMember(ctx, scopeMembersRead)
+}
+
+func isVisibility(visibility string) bool {
+	switch visibility {
+	case VisibilityPublic, VisibilityMembers, VisibilityAdmins, VisibilitySelf:
+		return true
+	}
+	return false
+}
+
+var memberPrivacyType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "MemberPrivacy",
+	Description: "회원 정보의 공개 범위 (PUBLIC, MEMBERS, ADMINS 또는 SELF)",
+	Fields: graphql.Fields{
+		"email": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return memberFromSource(params.Source).EmailVisibility, nil
+			},
+		},
+		"phoneNumber": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return memberFromSource(params.Source).PhoneNumberVisibility, nil
+			},
+		},
+		"studentID": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return memberFromSource(params.Source).StudentIDVisibility, nil
+			},
+		},
+	},
+})
+
+// publicMemberType은 게시물, 댓글의 작성자나 투표자처럼 다른 회원에게 보여지는 회원 정보입니다.
+var publicMemberType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PublicMember",
+	Fields: graphql.Fields{
+		"uuid":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"loginID":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"name":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"avatarURL": avatarURLField,
+	},
+})
+
+var memberPrivacyInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "MemberPrivacyInput",
+	Description: "회원 정보 공개 범위 InputObject",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"email":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"phoneNumber": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"studentID":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+// Mutations
+var UpdateMemberPrivacyMutation = &graphql.Field{
+	Type:        memberType,
+	Description: "회원 정보의 공개 범위를 수정합니다. 본인만 수정할 수 있습니다.",
+	Args: graphql.FieldConfigArgument{
+		"MemberPrivacyInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(memberPrivacyInputType)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member, _ := GetMemberByUUID(params.Context.Value("member").(*Member).UUID)
+
+		input := params.Args["MemberPrivacyInput"].(map[string]interface{})
+		for key, field := range map[string]*string{
+			"email":       &member.EmailVisibility,
+			"phoneNumber": &member.PhoneNumberVisibility,
+			"studentID":   &member.StudentIDVisibility,
+		} {
+			if input[key] == nil {
+				continue
+			} else if !isVisibility(input[key].(string)) {
+				return nil, fmt.Errorf("ERR400")
+			}
+			*field = input[key].(string)
+		}
+
+		errs := database.DB.Save(member).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return member, nil
+	},
+}
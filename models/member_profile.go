@@ -0,0 +1,204 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/graphql-go/graphql"
+
+	"nagase/components/database"
+)
+
+const (
+	memberBioMaxLength       = 1000
+	memberProfileRecentCount = 10
+)
+
+// AvatarURL은 프로필 사진의 주소를 반환합니다. 프로필 사진은 /files/에 업로드된 파일입니다.
+func (member *Member) AvatarURL() *string {
+	if member.AvatarFileName == "" {
+		return nil
+	}
+	avatarURL := "/files/" + member.AvatarFileName
+	return &avatarURL
+}
+
+var avatarURLField = &graphql.Field{
+	Type:        graphql.String,
+	Description: "프로필 사진 주소",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		return memberFromSource(params.Source).AvatarURL(), nil
+	},
+}
+
+var memberProfileType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "MemberProfile",
+	Description: "회원의 프로필 페이지 정보",
+	Fields: graphql.Fields{
+		"uuid":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"loginID":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"name":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"department": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"generation": &graphql.Field{Type: graphql.Int, Description: "기수"},
+		"avatarURL":  avatarURLField,
+		"bio":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"githubURL":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"blogURL":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"recentPosts": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postType))),
+			Description: "최근에 작성한 게시물. 조회하는 사용자가 읽을 수 있는 게시판의 게시물만 반환합니다.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				boardIDs := readableBoardIDs(requestMember(params.Context, scopePostsRead))
+				if len(boardIDs) == 0 {
+					return []Post{}, nil
+				}
+
+				var posts []Post
+				database.DB.Where("author_uuid = ? AND board_id IN (?) AND is_hidden = ?", memberFromSource(params.Source).UUID, boardIDs, false).
+					Order("id desc").Limit(memberProfileRecentCount).Find(&posts)
+				return posts, nil
+			},
+		},
+		"recentComments": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(commentType))),
+			Description: "최근에 작성한 댓글. 조회하는 사용자가 읽을 수 있는 게시판의 댓글만 반환합니다.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				boardIDs := readableBoardIDs(requestMember(params.Context, scopePostsRead))
+				if len(boardIDs) == 0 {
+					return []Comment{}, nil
+				}
+
+				var comments []Comment
+				database.DB.Joins("JOIN posts ON posts.id = comments.post_id").
+					Where("comments.author_uuid = ? AND posts.board_id IN (?) AND comments.is_hidden = ? AND posts.is_hidden = ?", memberFromSource(params.Source).UUID, boardIDs, false, false).
+					Order("comments.id desc").Limit(memberProfileRecentCount).Find(&comments)
+				return comments, nil
+			},
+		},
+		"projects": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(projectType))),
+			Description: "참여한 프로젝트",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				var projects []Project
+				database.DB.Joins("JOIN project_participants ON project_participants.project_id = projects.id").
+					Where("project_participants.member_uuid = ?", memberFromSource(params.Source).UUID).
+					Order("projects.id desc").Find(&projects)
+				return projects, nil
+			},
+		},
+	},
+})
+
+var memberProfileInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "MemberProfileInput",
+	Description: "회원 프로필 수정 InputObject",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"avatarFileName": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "/files/에 직접 업로드한 프로필 사진의 파일 이름. 빈 문자열이면 프로필 사진을 지웁니다.",
+		},
+		"bio":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"githubURL":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"blogURL":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"generation": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+	},
+})
+
+// Queries
+var MemberQuery = &graphql.Field{
+	Type:        memberProfileType,
+	Description: "회원의 프로필을 조회합니다. 활성화되지 않은 회원의 프로필은 조회할 수 없습니다.",
+	Args: graphql.FieldConfigArgument{
+		"loginID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		var member Member
+		database.DB.Where(&Member{LoginID: params.Args["loginID"].(string)}).First(&member)
+		if member.UUID == "" || !member.IsActivated {
+			return nil, fmt.Errorf("ERR400")
+		}
+		return member, nil
+	},
+}
+
+// Mutations
+var UpdateMemberProfileMutation = &graphql.Field{
+	Type:        memberProfileType,
+	Description: "회원 프로필을 수정합니다. 본인만 수정할 수 있습니다.",
+	Args: graphql.FieldConfigArgument{
+		"MemberProfileInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(memberProfileInputType)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member, _ := GetMemberByUUID(params.Context.Value("member").(*Member).UUID)
+
+		input := params.Args["MemberProfileInput"].(map[string]interface{})
+		if input["avatarFileName"] != nil {
+			fileName := input["avatarFileName"].(string)
+			if fileName != "" && !member.ownsFile(fileName) {
+				return nil, fmt.Errorf("ERR400")
+			}
+			member.AvatarFileName = fileName
+		}
+		if input["bio"] != nil {
+			member.Bio = strings.TrimSpace(input["bio"].(string))
+			if utf8.RuneCountInString(member.Bio) > memberBioMaxLength {
+				return nil, fmt.Errorf("ERR400")
+			}
+		}
+		if input["githubURL"] != nil {
+			member.GithubURL = strings.TrimSpace(input["githubURL"].(string))
+			if !isProfileURL(member.GithubURL) {
+				return nil, fmt.Errorf("ERR400")
+			}
+		}
+		if input["blogURL"] != nil {
+			member.BlogURL = strings.TrimSpace(input["blogURL"].(string))
+			if !isProfileURL(member.BlogURL) {
+				return nil, fmt.Errorf("ERR400")
+			}
+		}
+		if input["generation"] != nil {
+			generation := input["generation"].(int)
+			if generation < 1 {
+				return nil, fmt.Errorf("ERR400")
+			}
+			member.Generation = &generation
+		}
+
+		errs := database.DB.Save(member).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return member, nil
+	},
+}
+
+// Common functions
+
+// isProfileURL은 프로필에 링크로 보여줄 수 있는 http(s) 주소인지 확인합니다. 빈 문자열은 링크를 지우는 것으로 봅니다.
+func isProfileURL(rawURL string) bool {
+	if rawURL == "" {
+		return true
+	}
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// readableBoardIDs는 회원이 읽을 수 있는 게시판의 ID 목록을 반환합니다. member가 nil이면 로그인하지 않은 사용자로 봅니다.
+func readableBoardIDs(member *Member) []int {
+	var boards []Board
+	database.DB.Find(&boards)
+
+	boardIDs := []int{}
+	for _, board := range boards {
+		if member.canReadBoard(&board) {
+			boardIDs = append(boardIDs, board.ID)
+		}
+	}
+	return boardIDs
+}
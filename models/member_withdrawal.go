@@ -0,0 +1,213 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/jinzhu/gorm"
+
+	"nagase/components/database"
+	"nagase/components/email"
+)
+
+const (
+	withdrawnMemberName = "탈퇴한 회원"
+
+	// 탈퇴를 요청한 뒤 개인 정보가 지워지기까지의 유예 기간
+	memberWithdrawalGracePeriod   = 14 * 24 * time.Hour
+	memberWithdrawalPurgeInterval = time.Hour
+)
+
+var withdrawalEmailTitle = "회원 탈퇴 신청 안내"
+var withdrawalEmailBody = `
+안녕하세요,
+PoolC 홈페이지 회원 탈퇴 신청 안내 메일입니다.
+
+%s 계정의 탈퇴 신청이 접수되었습니다.
+%s 이후 회원님의 개인 정보는 삭제되며, 작성한 게시물과 댓글은 "탈퇴한 회원"의 글로 남습니다.
+그 전까지 다시 로그인하여 탈퇴 신청을 취소할 수 있습니다.
+
+본인이 요청하지 않은 경우, 바로 로그인하여 탈퇴 신청을 취소하고 비밀번호를 바꿔주세요.
+감사합니다.
+`
+
+// Mutations
+var WithdrawMemberMutation = &graphql.Field{
+	Type:        memberType,
+	Description: "회원 탈퇴를 신청합니다. 비밀번호를 다시 확인하며, 유예 기간(14일)이 지나면 개인 정보가 지워집니다.",
+	Args: graphql.FieldConfigArgument{
+		"password": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member, _ := GetMemberByUUID(params.Context.Value("member").(*Member).UUID)
+		if !member.ValidatePassword(params.Args["password"].(string)) {
+			return nil, fmt.Errorf("TKN000")
+		} else if member.WithdrawalRequestedAt != nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		now := time.Now()
+		member.WithdrawalRequestedAt = &now
+
+		tx := database.DB.Begin()
+		if err := tx.Save(member).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		// Sign out everywhere, so that only the member who knows the password can cancel the withdrawal.
+		if err := invalidateMemberTokensWithDB(tx, member); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return nil, err
+		}
+
+		mail := email.Email{
+			Title: withdrawalEmailTitle,
+			Body:  fmt.Sprintf(withdrawalEmailBody, member.LoginID, now.Add(memberWithdrawalGracePeriod).Format("2006-01-02 15:04")),
+			To:    member.Email,
+		}
+		go func() { mail.Send() }()
+
+		return member, nil
+	},
+}
+
+var CancelWithdrawalMutation = &graphql.Field{
+	Type:        memberType,
+	Description: "유예 기간 중인 회원 탈퇴 신청을 취소합니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member, _ := GetMemberByUUID(params.Context.Value("member").(*Member).UUID)
+		if member.WithdrawalRequestedAt == nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		member.WithdrawalRequestedAt = nil
+		errs := database.DB.Save(member).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return member, nil
+	},
+}
+
+// Common functions
+
+// getPublicMember는 게시물, 댓글의 작성자처럼 다른 회원에게 보여줄 회원을 가져옵니다.
+// 회원이 삭제된 경우 "탈퇴한 회원"을 반환합니다.
+func getPublicMember(memberUUID string) *Member {
+	member, err := GetMemberByUUID(memberUUID)
+	if err != nil {
+		return &Member{UUID: memberUUID, Name: withdrawnMemberName}
+	}
+	return member
+}
+
+// withdrawMember는 회원의 개인 정보를 지우고 탈퇴 처리합니다.
+// 회원의 행은 남겨두어, 작성한 게시물과 댓글은 "탈퇴한 회원"의 글로 보여집니다.
+func withdrawMember(member *Member) error {
+	// Only remove the avatar if the member uploaded it, so that other members' files are never deleted.
+	avatarFileName := member.AvatarFileName
+	if !member.ownsFile(avatarFileName) {
+		avatarFileName = ""
+	}
+
+	tx := database.DB.Begin()
+	if err := anonymizeMemberWithDB(tx, member); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	removeOrphanedDataExportFiles()
+	if avatarFileName != "" {
+		deleteFile(avatarFileName)
+	}
+	return nil
+}
+
+func anonymizeMemberWithDB(db *gorm.DB, member *Member) error {
+	now := time.Now()
+	*member = Member{
+		UUID: member.UUID,
+
+		// Keep unique columns unique with values that can never be used to sign in or sign up.
+		LoginID:   "_" + member.UUID,
+		Email:     member.UUID + "@withdrawn.invalid",
+		StudentID: "_" + member.UUID,
+		Name:      withdrawnMemberName,
+
+		EmailVisibility:       VisibilitySelf,
+		PhoneNumberVisibility: VisibilitySelf,
+		StudentIDVisibility:   VisibilitySelf,
+
+		InviteCodeID:          member.InviteCodeID,
+		TokenVersion:          member.TokenVersion + 1,
+		WithdrawalRequestedAt: member.WithdrawalRequestedAt,
+		WithdrawnAt:           &now,
+		MergedIntoUUID:        member.MergedIntoUUID,
+
+		CreatedAt: member.CreatedAt,
+	}
+	if err := db.Save(member).Error; err != nil {
+		return err
+	}
+	if err := revokeAllSessionsWithDB(db, member.UUID); err != nil {
+		return err
+	}
+
+	// Remove everything that identifies the member or lets anyone act as the member.
+	// Posts, comments, votes, projects and dues payments are kept.
+	for _, model := range []interface{}{
+		MemberRole{},
+		BoardModerator{},
+		MemberGroupMembership{},
+		PersonalAccessToken{},
+		ExternalIdentity{},
+		RecoveryCode{},
+		BoardSubscription{},
+		PostSubscription{},
+	} {
+		if err := db.Where("member_uuid = ?", member.UUID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	// Export files are removed by removeOrphanedDataExportFiles after the transaction is committed.
+	if err := db.Where(&DataExport{MemberUUID: member.UUID}).Delete(DataExport{}).Error; err != nil {
+		return err
+	}
+	applications := db.Model(&Application{}).Select("id").Where(&Application{MemberUUID: member.UUID}).QueryExpr()
+	if err := db.Where("application_id IN (?)", applications).Delete(ApplicationAnswer{}).Error; err != nil {
+		return err
+	}
+	return db.Where(&LoginAttempt{Key: memberLoginAttemptKey(member.UUID)}).Delete(LoginAttempt{}).Error
+}
+
+// purgeWithdrawnMembers는 탈퇴 유예 기간이 지난 회원의 개인 정보를 지웁니다.
+func purgeWithdrawnMembers() {
+	var members []Member
+	database.DB.Where("withdrawal_requested_at < ? AND withdrawn_at IS NULL", time.Now().Add(-memberWithdrawalGracePeriod)).Find(&members)
+	for i := range members {
+		if err := withdrawMember(&members[i]); err != nil {
+			fmt.Println("Failed to purge withdrawn member", members[i].UUID+":", err)
+		}
+	}
+}
+
+func init() {
+	go func() {
+		for range time.Tick(memberWithdrawalPurgeInterval) {
+			purgeWithdrawnMembers()
+		}
+	}()
+}
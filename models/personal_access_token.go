@@ -0,0 +1,336 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	uuid "github.com/satori/go.uuid"
+
+	"nagase/components/auth"
+	"nagase/components/database"
+)
+
+// Personal access token의 접두어와 권한 범위(scope)
+const (
+	PersonalAccessTokenPrefix = "ngp_"
+
+	scopeMembersRead = "members:read"
+	scopePostsRead   = "posts:read"
+	scopePostsWrite  = "posts:write"
+
+	personalAccessTokenDefaultDays = 90
+	personalAccessTokenMaxDays     = 365
+)
+
+var personalAccessTokenScopes = []string{scopeMembersRead, scopePostsRead, scopePostsWrite}
+
+// PersonalAccessToken은 봇이나 스크립트가 회원 대신 API를 호출할 때 사용하는 토큰입니다.
+// 토큰은 허용된 scope의 API만 호출할 수 있으며, 원문 대신 해시만 저장합니다.
+type PersonalAccessToken struct {
+	UUID string `gorm:"type:varchar(40);PRIMARY_KEY"`
+
+	MemberUUID  string `gorm:"type:varchar(40);INDEX"`
+	Name        string `gorm:"type:varchar(100)"`
+	TokenHash   string `gorm:"type:varchar(64);UNIQUE_INDEX"`
+	TokenPrefix string `gorm:"type:varchar(12)"`
+	Scopes      string `gorm:"type:varchar(255)"`
+	ExpiresAt   time.Time
+	LastUsedAt  *time.Time
+	RevokedAt   *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	// 토큰으로 인증한 회원. DB에 저장하지 않습니다.
+	Member *Member `gorm:"-"`
+}
+
+type CreatedPersonalAccessToken struct {
+	Token               string
+	PersonalAccessToken PersonalAccessToken
+}
+
+func (token *PersonalAccessToken) IsValid() bool {
+	return token.RevokedAt == nil && token.ExpiresAt.After(time.Now())
+}
+
+func (token *PersonalAccessToken) ScopeList() []string {
+	if token.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(token.Scopes, ",")
+}
+
+func (token *PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range token.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Touch는 토큰의 마지막 사용 시각을 갱신합니다. 요청마다 DB에 쓰지 않도록, 1분 이내에 갱신된 토큰은 건너뜁니다.
+func (token *PersonalAccessToken) Touch() {
+	if token.LastUsedAt != nil && time.Since(*token.LastUsedAt) < time.Minute {
+		return
+	}
+
+	now := time.Now()
+	token.LastUsedAt = &now
+	database.DB.Model(token).UpdateColumn("last_used_at", now)
+}
+
+var personalAccessTokenType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PersonalAccessToken",
+	Fields: graphql.Fields{
+		"uuid": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"tokenPrefix": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "토큰을 구분하기 위한 토큰의 앞부분",
+		},
+		"scopes": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				token := params.Source.(PersonalAccessToken)
+				return token.ScopeList(), nil
+			},
+		},
+		"expiresAt":  &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"lastUsedAt": &graphql.Field{Type: graphql.DateTime},
+		"revokedAt":  &graphql.Field{Type: graphql.DateTime},
+		"createdAt":  &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+	},
+})
+
+var createdPersonalAccessTokenType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CreatedPersonalAccessToken",
+	Fields: graphql.Fields{
+		"token": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "발급된 토큰. 이 응답에서만 확인할 수 있습니다.",
+		},
+		"personalAccessToken": &graphql.Field{Type: graphql.NewNonNull(personalAccessTokenType)},
+	},
+})
+
+// Queries
+var MyPersonalAccessTokensQuery = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(personalAccessTokenType))),
+	Description: "자신이 발급한 personal access token 목록을 조회합니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		return getPersonalAccessTokens(member.UUID), nil
+	},
+}
+
+var MemberPersonalAccessTokensQuery = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(personalAccessTokenType))),
+	Description: "회원의 personal access token 목록을 조회합니다. members.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"memberUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageMembers) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		return getPersonalAccessTokens(params.Args["memberUUID"].(string)), nil
+	},
+}
+
+// Mutations
+var SetMemberServiceAccountMutation = &graphql.Field{
+	Type:        memberType,
+	Description: "회원을 서비스 계정으로 지정하거나 지정을 해제합니다. 관리자 역할이 있는 회원은 지정할 수 없습니다. roles.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"memberUUID":       &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+		"isServiceAccount": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Boolean)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageRoles) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		member, err := GetMemberByUUID(params.Args["memberUUID"].(string))
+		if err != nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+		isServiceAccount := params.Args["isServiceAccount"].(bool)
+		if isServiceAccount && member.IsAdmin() {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		member.IsServiceAccount = isServiceAccount
+		errs := database.DB.Model(member).UpdateColumn("is_service_account", isServiceAccount).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return member, nil
+	},
+}
+
+var CreatePersonalAccessTokenMutation = &graphql.Field{
+	Type:        createdPersonalAccessTokenType,
+	Description: "Personal access token을 발급합니다. memberUUID를 지정하면 해당 서비스 계정의 토큰을 발급하며, roles.manage 권한이 필요합니다. 관리자 역할이 있는 계정의 토큰은 대신 발급할 수 없습니다. Personal access token으로는 토큰을 발급할 수 없습니다.",
+	Args: graphql.FieldConfigArgument{
+		"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+		"scopes": &graphql.ArgumentConfig{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+			Description: "members:read, posts:read, posts:write 중 토큰에 허용할 권한",
+		},
+		"expiresInDays": &graphql.ArgumentConfig{
+			Type:        graphql.Int,
+			Description: "토큰의 유효 기간 (기본값 90일, 최대 365일)",
+		},
+		"memberUUID": &graphql.ArgumentConfig{Type: graphql.String, Description: "토큰을 발급할 서비스 계정의 UUID"},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		memberUUID := member.UUID
+		if params.Args["memberUUID"] != nil {
+			if !member.Can(PermissionManageRoles) {
+				return nil, fmt.Errorf("ERR403")
+			}
+			target, err := GetMemberByUUID(params.Args["memberUUID"].(string))
+			if err != nil || !target.IsServiceAccount || target.IsAdmin() {
+				return nil, fmt.Errorf("ERR400")
+			}
+			memberUUID = target.UUID
+		}
+
+		// Validate the name, scopes and lifetime.
+		name := strings.TrimSpace(params.Args["name"].(string))
+		if name == "" || len(name) > 100 {
+			return nil, fmt.Errorf("ERR400")
+		}
+		var scopes []string
+		for _, s := range params.Args["scopes"].([]interface{}) {
+			if !isPersonalAccessTokenScope(s.(string)) {
+				return nil, fmt.Errorf("ERR400")
+			}
+			scopes = append(scopes, s.(string))
+		}
+		if len(scopes) == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+		days := personalAccessTokenDefaultDays
+		if params.Args["expiresInDays"] != nil {
+			days = params.Args["expiresInDays"].(int)
+		}
+		if days < 1 || days > personalAccessTokenMaxDays {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		secret, err := auth.GenerateRefreshToken()
+		if err != nil {
+			return nil, fmt.Errorf("ERR500")
+		}
+		tokenString := PersonalAccessTokenPrefix + secret
+
+		token := PersonalAccessToken{
+			UUID:        uuid.NewV4().String(),
+			MemberUUID:  memberUUID,
+			Name:        name,
+			TokenHash:   auth.HashRefreshToken(tokenString),
+			TokenPrefix: tokenString[:len(PersonalAccessTokenPrefix)+4],
+			Scopes:      strings.Join(scopes, ","),
+			ExpiresAt:   time.Now().AddDate(0, 0, days),
+		}
+		errs := database.DB.Create(&token).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+
+		return CreatedPersonalAccessToken{Token: tokenString, PersonalAccessToken: token}, nil
+	},
+}
+
+var RevokePersonalAccessTokenMutation = &graphql.Field{
+	Type:        personalAccessTokenType,
+	Description: "Personal access token을 폐기합니다. 다른 회원의 토큰을 폐기하려면 members.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"tokenUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		var token PersonalAccessToken
+		database.DB.Where(&PersonalAccessToken{UUID: params.Args["tokenUUID"].(string)}).First(&token)
+		if token.UUID == "" {
+			return nil, fmt.Errorf("ERR400")
+		} else if token.MemberUUID != member.UUID && !member.Can(PermissionManageMembers) {
+			return nil, fmt.Errorf("ERR403")
+		}
+
+		if token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+			errs := database.DB.Save(&token).GetErrors()
+			if len(errs) > 0 {
+				return nil, errs[0]
+			}
+		}
+		return token, nil
+	},
+}
+
+// Common functions
+func isPersonalAccessTokenScope(scope string) bool {
+	for _, s := range personalAccessTokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func getPersonalAccessTokens(memberUUID string) []PersonalAccessToken {
+	var tokens []PersonalAccessToken
+	database.DB.Where("member_uuid = ? and revoked_at is null and expires_at > ?", memberUUID, time.Now()).Order("created_at desc").Find(&tokens)
+	return tokens
+}
+
+// GetMemberByPersonalAccessToken은 personal access token을 검증하고, 회원 정보가 담긴 토큰을 반환합니다.
+func GetMemberByPersonalAccessToken(tokenString string) (*PersonalAccessToken, error) {
+	var token PersonalAccessToken
+	database.DB.Where(&PersonalAccessToken{TokenHash: auth.HashRefreshToken(tokenString)}).First(&token)
+	if token.UUID == "" || !token.IsValid() {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	member, err := GetMemberByUUID(token.MemberUUID)
+	if err != nil || !member.IsActivated {
+		return nil, fmt.Errorf("invalid member")
+	}
+
+	token.Member = member
+	return &token, nil
+}
+
+// requestMember는 요청한 회원을 반환합니다.
+// Personal access token으로 인증한 요청은, 토큰에 scope가 허용된 경우에만 회원을 반환합니다.
+func requestMember(ctx context.Context, scope string) *Member {
+	if member, ok := ctx.Value("member").(*Member); ok {
+		return member
+	}
+	if token, ok := ctx.Value("personalAccessToken").(*PersonalAccessToken); ok && token.HasScope(scope) {
+		return token.Member
+	}
+	return nil
+}
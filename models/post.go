@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/graphql-go/graphql"
+	"github.com/jinzhu/gorm"
 
 	"nagase/components/database"
 	"nagase/components/push"
@@ -22,6 +23,13 @@ type Post struct {
 	Body       string
 	VoteID     *int
 
+	// 게시판 안에서 게시물을 가리키는 주소. 제목으로 만들며, 이전 주소는 PostSlug에 남습니다.
+	Slug string `gorm:"type:varchar(255);INDEX"`
+
+	// 게시판 운영자가 숨기거나 게시판 상단에 고정한 게시물
+	IsHidden bool `gorm:"default:false"`
+	IsPinned bool `gorm:"default:false"`
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -32,13 +40,17 @@ var postType = graphql.NewObject(graphql.ObjectConfig{
 		// To ignore circular dependency, `board` field has initialized lazily on init() method.
 		"id": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
 		"author": &graphql.Field{
-			Type: graphql.NewNonNull(memberType),
+			Type: graphql.NewNonNull(publicMemberType),
 			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-				return GetMemberByUUID(params.Source.(Post).AuthorUUID)
+				return getPublicMember(params.Source.(Post).AuthorUUID), nil
 			},
 		},
 		"title": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
-		"body":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"slug": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "게시판 안에서 게시물을 가리키는 현재 주소. 예전 주소로 조회했다면 이 값으로 이동해야 합니다.",
+		},
+		"body": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
 		"vote": &graphql.Field{
 			Type: voteType,
 			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
@@ -51,11 +63,20 @@ var postType = graphql.NewObject(graphql.ObjectConfig{
 				return vote, nil
 			},
 		},
+		"isHidden": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"isPinned": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
 		"comments": &graphql.Field{
-			Type: graphql.NewList(graphql.NewNonNull(commentType)),
+			Type:        graphql.NewList(graphql.NewNonNull(commentType)),
+			Description: "댓글 목록. 숨긴 댓글은 작성자와 게시판 운영자에게만 보입니다.",
 			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				post := params.Source.(Post)
+				query := database.DB.Where(&Comment{PostID: post.ID})
+				if member := requestMember(params.Context, scopePostsRead); !member.canModerateBoard(post.BoardID) {
+					query = visibleToAuthor(query, member)
+				}
+
 				var comments []Comment
-				database.DB.Where(&Comment{PostID: params.Source.(Post).ID}).Order("id asc").Find(&comments)
+				query.Order("id asc").Find(&comments)
 				return comments, nil
 			},
 		},
@@ -90,14 +111,20 @@ var postPageType = graphql.NewObject(graphql.ObjectConfig{
 	},
 })
 
-func getPostPage(boardID int, pagination *Pagination) PostPage {
+// getPostPage는 게시판의 게시물 목록을 가져옵니다. 숨긴 게시물은 작성자와 게시판 운영자에게만 보입니다.
+func getPostPage(boardID int, member *Member, pagination *Pagination) PostPage {
 	count := 20
 	if pagination.Count != 0 {
 		count = pagination.Count
 	}
 
+	visible := database.DB.Where(Post{BoardID: boardID})
+	if !member.canModerateBoard(boardID) {
+		visible = visibleToAuthor(visible, member)
+	}
+
 	var posts []Post
-	query := database.DB.Where(Post{BoardID: boardID})
+	query := visible
 	if pagination.Before != 0 {
 		query = query.Where("id < ?", pagination.Before).Order("id desc")
 	} else if pagination.After != 0 {
@@ -121,8 +148,8 @@ func getPostPage(boardID int, pagination *Pagination) PostPage {
 
 	var prevCount int
 	var nextCount int
-	database.DB.Model(&Post{}).Where("board_id = ? and id > ?", boardID, maxID).Count(&prevCount)
-	database.DB.Model(&Post{}).Where("board_id = ? and id < ?", boardID, minID).Count(&nextCount)
+	visible.Model(&Post{}).Where("id > ?", maxID).Count(&prevCount)
+	visible.Model(&Post{}).Where("id < ?", minID).Count(&nextCount)
 	return PostPage{
 		Posts: posts,
 		PageInfo: PageInfo{
@@ -148,31 +175,35 @@ var postSubscriptionType = graphql.NewObject(graphql.ObjectConfig{
 // Queries
 var PostQuery = &graphql.Field{
 	Type:        postType,
-	Description: "게시물을 조회합니다.",
+	Description: "게시물을 조회합니다. postID 또는 boardURLPath와 slug로 조회합니다. 예전 주소로도 조회할 수 있습니다.",
 	Args: graphql.FieldConfigArgument{
-		"postID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"postID":       &graphql.ArgumentConfig{Type: graphql.Int},
+		"boardURLPath": &graphql.ArgumentConfig{Type: graphql.String, Description: "게시판의 URL 경로"},
+		"slug":         &graphql.ArgumentConfig{Type: graphql.String, Description: "게시물의 주소"},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		var member *Member
-		if memberCtx := params.Context.Value("member"); memberCtx != nil {
-			member = memberCtx.(*Member)
-		}
+		member := requestMember(params.Context, scopePostsRead)
 
 		// Get post
-		postID, _ := params.Args["postID"].(int)
 		var post Post
-		database.DB.Where(&Post{ID: postID}).First(&post)
+		boardURLPath, _ := params.Args["boardURLPath"].(string)
+		slug, _ := params.Args["slug"].(string)
+		if postID, ok := params.Args["postID"].(int); ok {
+			database.DB.Where(&Post{ID: postID}).First(&post)
+		} else if boardURLPath != "" && slug != "" {
+			found, err := getPostBySlug(boardURLPath, slug)
+			if err != nil {
+				return nil, err
+			}
+			post = *found
+		}
 		if post.ID == 0 {
 			return nil, fmt.Errorf("ERR400")
 		}
 
-		// Get board and check permission
-		var board Board
-		database.DB.Where(&Board{ID: post.BoardID}).First(&board)
-		if (board.ReadPermission != "PUBLIC" && member == nil) || (board.ReadPermission == "ADMIN" && !member.IsAdmin) {
+		if !member.canReadPost(&post) {
 			return nil, fmt.Errorf("ERR403")
 		}
-
 		return post, nil
 	},
 }
@@ -187,20 +218,17 @@ var PostPageQuery = &graphql.Field{
 		"count":   &graphql.ArgumentConfig{Type: graphql.Int},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		var member *Member
-		if memberCtx := params.Context.Value("member"); memberCtx != nil {
-			member = memberCtx.(*Member)
-		}
+		member := requestMember(params.Context, scopePostsRead)
 
 		// Get board and check permission
 		var board Board
 		boardID, _ := params.Args["boardID"].(int)
 		database.DB.Where(&Board{ID: boardID}).First(&board)
-		if (board.ReadPermission != "PUBLIC" && member == nil) || (board.ReadPermission == "ADMIN" && !member.IsAdmin) {
+		if !member.canReadBoard(&board) {
 			return nil, fmt.Errorf("ERR403")
 		}
 
-		return getPostPage(boardID, getPaginationFromGraphQLParams(&params)), nil
+		return getPostPage(boardID, member, getPaginationFromGraphQLParams(&params)), nil
 	},
 }
 
@@ -226,10 +254,10 @@ var CreatePostMutation = &graphql.Field{
 		"VoteInput": &graphql.ArgumentConfig{Type: voteInputType},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if params.Context.Value("member") == nil {
+		member := requestMember(params.Context, scopePostsWrite)
+		if member == nil {
 			return nil, fmt.Errorf("ERR401")
 		}
-		member := params.Context.Value("member").(*Member)
 
 		// 해당 게시판에 권한이 있는지 확인합니다.
 		boardID, _ := params.Args["boardID"].(int)
@@ -237,7 +265,7 @@ var CreatePostMutation = &graphql.Field{
 		database.DB.Where(&Board{ID: boardID}).First(&board)
 		if board.Name == "" {
 			return nil, fmt.Errorf("ERR400")
-		} else if board.WritePermission == "ADMIN" && !member.IsAdmin {
+		} else if !member.canWriteBoard(board) {
 			return nil, fmt.Errorf("ERR403")
 		}
 
@@ -288,6 +316,9 @@ var CreatePostMutation = &graphql.Field{
 		if len(errs) > 0 {
 			return nil, errs[0]
 		}
+		if err := updatePostSlugWithDB(database.DB, &post); err != nil {
+			return nil, err
+		}
 
 		// 게시물이 작성된 게시판을 구독하고 있는 유저들에게 푸시를 발송합니다.
 		data := make(map[string]string)
@@ -318,10 +349,10 @@ var UpdatePostMutation = &graphql.Field{
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
 		// Check permission to the post.
-		if params.Context.Value("member") == nil {
+		member := requestMember(params.Context, scopePostsWrite)
+		if member == nil {
 			return nil, fmt.Errorf("ERR401")
 		}
-		member := params.Context.Value("member").(*Member)
 
 		var post Post
 		postID, _ := params.Args["postID"].(int)
@@ -339,6 +370,9 @@ var UpdatePostMutation = &graphql.Field{
 			post.Body = postInput["body"].(string)
 		}
 		database.DB.Save(&post)
+		if err := updatePostSlugWithDB(database.DB, &post); err != nil {
+			return nil, err
+		}
 
 		return post, nil
 	},
@@ -346,7 +380,7 @@ var UpdatePostMutation = &graphql.Field{
 
 var DeletePostMutation = &graphql.Field{
 	Type:        postType,
-	Description: "게시물을 삭제합니다. 게시물의 작성자이거나 관리자 권한이 필요합니다.",
+	Description: "게시물을 삭제합니다. 게시물의 작성자이거나 게시판 운영자(posts.moderate 권한 포함)이어야 합니다.",
 	Args: graphql.FieldConfigArgument{
 		"postID": &graphql.ArgumentConfig{
 			Type:        graphql.NewNonNull(graphql.Int),
@@ -355,15 +389,15 @@ var DeletePostMutation = &graphql.Field{
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
 		// Check permission to the post.
-		if params.Context.Value("member") == nil {
+		member := requestMember(params.Context, scopePostsWrite)
+		if member == nil {
 			return nil, fmt.Errorf("ERR401")
 		}
-		member := params.Context.Value("member").(*Member)
 
 		var post Post
 		postID, _ := params.Args["postID"].(int)
 		database.DB.Where(&Post{ID: postID}).First(&post)
-		if post.ID == 0 || (!member.IsAdmin && post.AuthorUUID != member.UUID) {
+		if post.ID == 0 || (!member.canModerateBoard(post.BoardID) && post.AuthorUUID != member.UUID) {
 			return nil, fmt.Errorf("ERR401")
 		}
 
@@ -373,6 +407,7 @@ var DeletePostMutation = &graphql.Field{
 		}
 
 		// Delete the post.
+		database.DB.Where(&PostSlug{PostID: post.ID}).Delete(PostSlug{})
 		database.DB.Delete(&post)
 		return post, nil
 	},
@@ -435,3 +470,21 @@ func init() {
 		},
 	})
 }
+
+// canReadPost는 게시물을 볼 수 있는지 확인합니다. 게시판의 읽기 권한이 필요하며, 숨긴 게시물은 작성자와 게시판 운영자만 볼 수 있습니다.
+func (member *Member) canReadPost(post *Post) bool {
+	var board Board
+	database.DB.Where("id = ?", post.BoardID).First(&board)
+	if board.ID == 0 || !member.canReadBoard(&board) {
+		return false
+	}
+	return !post.IsHidden || (member != nil && post.AuthorUUID == member.UUID) || member.canModerateBoard(board.ID)
+}
+
+// visibleToAuthor는 숨기지 않은 기록과 회원이 직접 작성한 기록만 조회하도록 합니다. 회원은 nil일 수 있습니다.
+func visibleToAuthor(query *gorm.DB, member *Member) *gorm.DB {
+	if member == nil {
+		return query.Where("is_hidden = ?", false)
+	}
+	return query.Where("is_hidden = ? OR author_uuid = ?", false, member.UUID)
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jinzhu/gorm"
+
+	"nagase/components/database"
+)
+
+const postSlugMaxLength = 80
+
+// PostSlug는 게시판 안에서 게시물을 가리키는 주소입니다.
+// 제목이 바뀌거나 게시물이 다른 게시판으로 옮겨져도 이전 주소는 지우지 않아, 예전 링크로도 게시물을 찾을 수 있습니다.
+type PostSlug struct {
+	BoardID int    `gorm:"PRIMARY_KEY;auto_increment:false"`
+	Slug    string `gorm:"type:varchar(255);PRIMARY_KEY"`
+	PostID  int    `gorm:"INDEX"`
+
+	CreatedAt time.Time
+}
+
+// slugify는 제목을 소문자와 숫자, 한글만 남기고 나머지는 '-'로 이어 붙인 주소로 바꿉니다.
+func slugify(title string) string {
+	var b strings.Builder
+	length := 0
+	separated := true
+	for _, r := range strings.ToLower(title) {
+		if length >= postSlugMaxLength {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			separated = false
+			length++
+		} else if !separated {
+			b.WriteRune('-')
+			separated = true
+			length++
+		}
+	}
+
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		return "post"
+	}
+	return slug
+}
+
+// updatePostSlugWithDB는 게시물의 제목과 게시판으로 주소를 정합니다. 게시판에 같은 주소의 다른 게시물이 있으면 뒤에 번호를 붙입니다.
+func updatePostSlugWithDB(db *gorm.DB, post *Post) error {
+	base := slugify(post.Title)
+	for n := 1; ; n++ {
+		candidate := base
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, n)
+		}
+
+		var existing PostSlug
+		db.Where("board_id = ? AND slug = ?", post.BoardID, candidate).First(&existing)
+		if existing.PostID == 0 {
+			if err := db.Create(&PostSlug{BoardID: post.BoardID, Slug: candidate, PostID: post.ID}).Error; err != nil {
+				// Another post may have taken the slug concurrently, so look it up again and try the next one.
+				db.Where("board_id = ? AND slug = ?", post.BoardID, candidate).First(&existing)
+				if existing.PostID == 0 {
+					return err
+				}
+			} else {
+				existing.PostID = post.ID
+			}
+		}
+		if existing.PostID != post.ID {
+			continue
+		}
+
+		if post.Slug != candidate {
+			post.Slug = candidate
+			return db.Model(post).UpdateColumn("slug", candidate).Error
+		}
+		return nil
+	}
+}
+
+// getPostBySlug는 게시판 주소와 게시물 주소로 게시물을 찾습니다. 예전 주소로도 찾을 수 있습니다.
+func getPostBySlug(boardURLPath string, slug string) (*Post, error) {
+	if boardURLPath == "" || slug == "" {
+		return nil, fmt.Errorf("ERR400")
+	}
+
+	var board Board
+	database.DB.Where("url_path = ?", boardURLPath).First(&board)
+	if board.ID == 0 {
+		return nil, fmt.Errorf("ERR400")
+	}
+
+	var postSlug PostSlug
+	database.DB.Where("board_id = ? AND slug = ?", board.ID, slug).First(&postSlug)
+	if postSlug.PostID == 0 {
+		return nil, fmt.Errorf("ERR400")
+	}
+
+	var post Post
+	database.DB.Where(&Post{ID: postSlug.PostID}).First(&post)
+	if post.ID == 0 {
+		return nil, fmt.Errorf("ERR400")
+	}
+	return &post, nil
+}
+
+// migratePostSlugs는 주소가 없는 기존 게시물에 주소를 정합니다.
+func migratePostSlugs() {
+	var posts []Post
+	database.DB.Where("slug IS NULL OR slug = ?", "").Order("id asc").Find(&posts)
+	for i := range posts {
+		if err := updatePostSlugWithDB(database.DB, &posts[i]); err != nil {
+			fmt.Println("Failed to set the slug of post", posts[i].ID, err)
+		}
+	}
+}
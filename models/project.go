@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/graphql-go/graphql"
+	"github.com/jinzhu/gorm"
 
 	"nagase/components/database"
 )
@@ -24,6 +25,14 @@ type Project struct {
 	UpdatedAt time.Time
 }
 
+// ProjectParticipant는 프로젝트에 참여한 회원입니다. 회원 프로필의 프로젝트 목록에 사용합니다.
+type ProjectParticipant struct {
+	ProjectID  int    `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false"`
+	MemberUUID string `gorm:"type:varchar(40);PRIMARY_KEY"`
+
+	CreatedAt time.Time
+}
+
 var projectType = graphql.NewObject(graphql.ObjectConfig{
 	Name: "Project",
 	Fields: graphql.Fields{
@@ -35,6 +44,13 @@ var projectType = graphql.NewObject(graphql.ObjectConfig{
 		"duration":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
 		"thumbnailURL": &graphql.Field{Type: graphql.String},
 		"body":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"members": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(publicMemberType))),
+			Description: "프로젝트에 참여한 회원",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return getProjectParticipants(params.Source.(Project).ID), nil
+			},
+		},
 	},
 })
 
@@ -49,6 +65,10 @@ var projectInputType = graphql.NewInputObject(graphql.InputObjectConfig{
 		"duration":     &graphql.InputObjectFieldConfig{Type: graphql.String},
 		"thumbnailURL": &graphql.InputObjectFieldConfig{Type: graphql.String},
 		"body":         &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"memberUUIDs": &graphql.InputObjectFieldConfig{
+			Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+			Description: "프로젝트에 참여한 회원의 UUID 목록",
+		},
 	},
 })
 
@@ -82,12 +102,12 @@ var ProjectQuery = &graphql.Field{
 // Mutations
 var CreateProjectMutation = &graphql.Field{
 	Type:        projectType,
-	Description: "프로젝트를 추가합니다. 관리자 권한이 필요합니다.",
+	Description: "프로젝트를 추가합니다. projects.manage 권한이 필요합니다.",
 	Args: graphql.FieldConfigArgument{
 		"ProjectInput": &graphql.ArgumentConfig{Type: projectInputType},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if member := params.Context.Value("member"); member == nil || !member.(*Member).IsAdmin {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageProjects) {
 			return nil, fmt.Errorf("ERR401")
 		}
 
@@ -101,24 +121,19 @@ var CreateProjectMutation = &graphql.Field{
 			ThumbnailURL: prjInput["thumbnailURL"].(string),
 			Body:         prjInput["body"].(string),
 		}
-		errs := database.DB.Save(&prj).GetErrors()
-		if len(errs) > 0 {
-			return nil, errs[0]
-		}
-
-		return prj, nil
+		return saveProject(&prj, prjInput)
 	},
 }
 
 var UpdateProjectMutation = &graphql.Field{
 	Type:        projectType,
-	Description: "프로젝트를 수정합니다. 관리자 권한이 필요합니다.",
+	Description: "프로젝트를 수정합니다. projects.manage 권한이 필요합니다.",
 	Args: graphql.FieldConfigArgument{
 		"projectID":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
 		"ProjectInput": &graphql.ArgumentConfig{Type: projectInputType},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if member := params.Context.Value("member"); member == nil || !member.(*Member).IsAdmin {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageProjects) {
 			return nil, fmt.Errorf("ERR401")
 		}
 
@@ -150,24 +165,18 @@ var UpdateProjectMutation = &graphql.Field{
 		if prjInput["body"] != nil {
 			prj.Body = prjInput["body"].(string)
 		}
-
-		errs := database.DB.Save(&prj).GetErrors()
-		if len(errs) > 0 {
-			return nil, errs[0]
-		}
-
-		return prj, nil
+		return saveProject(&prj, prjInput)
 	},
 }
 
 var DeleteProjectMutation = &graphql.Field{
 	Type:        projectType,
-	Description: "프로젝트를 삭제합니다. 관리자 권한이 필요합니다.",
+	Description: "프로젝트를 삭제합니다. projects.manage 권한이 필요합니다.",
 	Args: graphql.FieldConfigArgument{
 		"projectID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if member := params.Context.Value("member"); member == nil || !member.(*Member).IsAdmin {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageProjects) {
 			return nil, fmt.Errorf("ERR401")
 		}
 
@@ -177,6 +186,7 @@ var DeleteProjectMutation = &graphql.Field{
 			return nil, fmt.Errorf("ERR400")
 		}
 
+		database.DB.Where(&ProjectParticipant{ProjectID: prj.ID}).Delete(ProjectParticipant{})
 		errs := database.DB.Delete(&prj).GetErrors()
 		if len(errs) > 0 {
 			return nil, errs[0]
@@ -185,3 +195,44 @@ var DeleteProjectMutation = &graphql.Field{
 		return prj, nil
 	},
 }
+
+// Common functions
+func saveProject(prj *Project, prjInput map[string]interface{}) (interface{}, error) {
+	tx := database.DB.Begin()
+	if errs := tx.Save(prj).GetErrors(); len(errs) > 0 {
+		tx.Rollback()
+		return nil, errs[0]
+	}
+	if prjInput["memberUUIDs"] != nil {
+		if err := setProjectParticipantsWithDB(tx, prj.ID, prjInput["memberUUIDs"].([]interface{})); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return *prj, nil
+}
+
+func getProjectParticipants(projectID int) []Member {
+	var members []Member
+	database.DB.Joins("JOIN project_participants ON project_participants.member_uuid = members.uuid").
+		Where("project_participants.project_id = ?", projectID).Order("members.name asc").Find(&members)
+	return members
+}
+
+func setProjectParticipantsWithDB(db *gorm.DB, projectID int, memberUUIDs []interface{}) error {
+	if err := db.Where(&ProjectParticipant{ProjectID: projectID}).Delete(ProjectParticipant{}).Error; err != nil {
+		return err
+	}
+	for _, memberUUID := range memberUUIDs {
+		if _, err := GetMemberByUUID(memberUUID.(string)); err != nil {
+			return fmt.Errorf("ERR400")
+		}
+		if err := db.Save(&ProjectParticipant{ProjectID: projectID, MemberUUID: memberUUID.(string)}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
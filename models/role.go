@@ -0,0 +1,495 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/jinzhu/gorm"
+
+	"nagase/components/database"
+)
+
+// 권한 목록. 역할(Role)에 권한을 부여하고, 회원에게 역할을 부여합니다.
+const (
+	PermissionManageMembers      = "members.manage"
+	PermissionInviteMembers      = "members.invite"
+	PermissionManageApplications = "applications.manage"
+	PermissionReviewApplications = "applications.review"
+	PermissionManageRoles        = "roles.manage"
+	PermissionManageBoards       = "boards.manage"
+	PermissionAccessStaff        = "boards.staff"
+	PermissionModeratePosts      = "posts.moderate"
+	PermissionManageProjects     = "projects.manage"
+	PermissionManageTerms        = "terms.manage"
+	PermissionManageGroups       = "groups.manage"
+)
+
+var permissionDescriptions = map[string]string{
+	PermissionManageMembers:      "회원 목록 조회, 활성화, 삭제, 계정 잠금 해제, 2단계 인증 요구, 다른 회원의 세션 및 토큰 관리",
+	PermissionInviteMembers:      "회원가입 초대 코드 발급/폐기 및 코드별 가입 회원 조회",
+	PermissionManageApplications: "가입 신청서 양식 추가/수정 및 가입 신청 승인/거절",
+	PermissionReviewApplications: "가입 신청서 조회 및 심사 의견, 찬반 투표 작성",
+	PermissionManageRoles:        "역할 추가/수정/삭제 및 회원에게 역할 부여",
+	PermissionManageBoards:       "게시판 및 게시판 분류 추가/수정/삭제",
+	PermissionAccessStaff:        "운영진 전용(boards.staff) 게시판 읽기/쓰기",
+	PermissionModeratePosts:      "모든 게시판에서 다른 회원의 게시물 및 댓글 삭제, 숨김, 이동, 고정",
+	PermissionManageProjects:     "프로젝트 추가/수정/삭제",
+	PermissionManageTerms:        "학기 추가/수정, 학기별 회원 등록 및 회비 납부 기록",
+	PermissionManageGroups:       "회원 그룹 추가/수정/삭제 및 모든 그룹의 회원 관리",
+}
+
+// 게시판 읽기/쓰기 권한에 권한 이름 대신 사용할 수 있는 값
+const (
+	BoardAccessPublic = "PUBLIC" // 로그인하지 않은 사용자를 포함한 모든 사용자
+	BoardAccessMember = "MEMBER" // 현재 학기에 등록된 회원
+	BoardAccessGroups = "GROUPS" // 게시판에서 권한을 받은 그룹의 회원만
+)
+
+// AdminRoleName은 모든 권한을 가진 기본 역할입니다. 기존의 관리자(IsAdmin) 회원은 이 역할로 옮겨집니다.
+const AdminRoleName = "admin"
+
+type Role struct {
+	ID int
+
+	Name        string `gorm:"type:varchar(40);UNIQUE_INDEX"`
+	Description string `gorm:"type:varchar(255)"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type RolePermission struct {
+	RoleID     int    `gorm:"PRIMARY_KEY;auto_increment:false"`
+	Permission string `gorm:"type:varchar(40);PRIMARY_KEY"`
+}
+
+type MemberRole struct {
+	MemberUUID string `gorm:"type:varchar(40);PRIMARY_KEY"`
+	RoleID     int    `gorm:"PRIMARY_KEY;auto_increment:false"`
+
+	CreatedAt time.Time
+}
+
+func (role *Role) Permissions() []string {
+	var permissions []string
+	database.DB.Model(&RolePermission{}).Where(&RolePermission{RoleID: role.ID}).Order("permission asc").Pluck("permission", &permissions)
+	return permissions
+}
+
+// Can은 회원이 부여받은 역할 중 하나라도 권한을 가지고 있는지 확인합니다.
+// 모든 resolver의 권한 확인은 이 함수를 통해 이루어집니다.
+func (member *Member) Can(permission string) bool {
+	if member == nil {
+		return false
+	}
+	if member.grantedPermissions == nil {
+		member.grantedPermissions = make(map[string]bool)
+		for _, p := range getMemberPermissions(member.UUID) {
+			member.grantedPermissions[p] = true
+		}
+	}
+	return member.grantedPermissions[permission]
+}
+
+// canAccessBoard는 게시판의 읽기/쓰기 권한 값에 대해 회원의 접근 가능 여부를 확인합니다. 회원은 nil일 수 있습니다.
+func (member *Member) canAccessBoard(access string) bool {
+	switch access {
+	case BoardAccessPublic:
+		return true
+	case BoardAccessMember:
+		return member.hasActiveMembership()
+	case BoardAccessGroups:
+		return false
+	default:
+		return member.Can(access)
+	}
+}
+
+// 게시판의 읽기/쓰기 권한이 없더라도, 회원이 속한 그룹이 게시판에서 권한을 받은 경우 허용합니다.
+func (member *Member) canReadBoard(board *Board) bool {
+	return member.canAccessBoard(board.ReadPermission) || member.hasBoardGroupAccess(board.ID, "can_read")
+}
+
+func (member *Member) canWriteBoard(board *Board) bool {
+	return !board.isArchived() && (member.canAccessBoard(board.WritePermission) || member.hasBoardGroupAccess(board.ID, "can_write"))
+}
+
+// canCommentBoard는 게시판에 댓글을 달 수 있는지 확인합니다. 게시판의 읽기 권한이 있는 회원은 댓글을 달 수 있습니다.
+func (member *Member) canCommentBoard(board *Board) bool {
+	return !board.isArchived() && (member.canAccessBoard(board.ReadPermission) || member.hasBoardGroupAccess(board.ID, "can_comment"))
+}
+
+// IsAdmin은 회원에게 관리자 역할이 있는지 확인합니다.
+func (member *Member) IsAdmin() bool {
+	for _, role := range getMemberRoles(member.UUID) {
+		if role.Name == AdminRoleName {
+			return true
+		}
+	}
+	return false
+}
+
+var roleType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Role",
+	Fields: graphql.Fields{
+		"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"name":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"description": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"permissions": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				role := params.Source.(Role)
+				return role.Permissions(), nil
+			},
+		},
+	},
+})
+
+type Permission struct {
+	Name        string
+	Description string
+}
+
+var permissionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Permission",
+	Fields: graphql.Fields{
+		"name":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"description": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var roleInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "RoleInput",
+	Description: "역할 추가/수정 InputObject",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"description": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"permissions": &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+	},
+})
+
+// Queries
+var RolesQuery = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(roleType))),
+	Description: "역할 목록을 조회합니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var roles []Role
+		database.DB.Order("id asc").Find(&roles)
+		return roles, nil
+	},
+}
+
+var PermissionsQuery = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(permissionType))),
+	Description: "역할에 부여할 수 있는 권한 목록을 조회합니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		return allPermissions(), nil
+	},
+}
+
+// Mutations
+var CreateRoleMutation = &graphql.Field{
+	Type:        roleType,
+	Description: "역할을 추가합니다. roles.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"RoleInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(roleInputType)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageRoles) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		roleInput := params.Args["RoleInput"].(map[string]interface{})
+		role := Role{}
+		if roleInput["name"] != nil {
+			role.Name = strings.TrimSpace(roleInput["name"].(string))
+		}
+		if roleInput["description"] != nil {
+			role.Description = roleInput["description"].(string)
+		}
+		if role.Name == "" {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		var duplicated Role
+		database.DB.Where(&Role{Name: role.Name}).First(&duplicated)
+		if duplicated.ID != 0 {
+			return nil, fmt.Errorf("ROL000")
+		}
+
+		if err := saveRole(&role, roleInput["permissions"]); err != nil {
+			return nil, err
+		}
+		return role, nil
+	},
+}
+
+var UpdateRoleMutation = &graphql.Field{
+	Type:        roleType,
+	Description: "역할을 수정합니다. roles.manage 권한이 필요하며, admin 역할의 이름과 권한은 바꿀 수 없습니다.",
+	Args: graphql.FieldConfigArgument{
+		"roleID":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"RoleInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(roleInputType)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageRoles) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var role Role
+		database.DB.Where(&Role{ID: params.Args["roleID"].(int)}).First(&role)
+		if role.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		roleInput := params.Args["RoleInput"].(map[string]interface{})
+		if role.Name == AdminRoleName && (roleInput["name"] != nil || roleInput["permissions"] != nil) {
+			return nil, fmt.Errorf("ROL001")
+		}
+		if roleInput["name"] != nil {
+			name := strings.TrimSpace(roleInput["name"].(string))
+			var duplicated Role
+			database.DB.Where(&Role{Name: name}).First(&duplicated)
+			if name == "" {
+				return nil, fmt.Errorf("ERR400")
+			} else if duplicated.ID != 0 && duplicated.ID != role.ID {
+				return nil, fmt.Errorf("ROL000")
+			}
+			role.Name = name
+		}
+		if roleInput["description"] != nil {
+			role.Description = roleInput["description"].(string)
+		}
+
+		if err := saveRole(&role, roleInput["permissions"]); err != nil {
+			return nil, err
+		}
+		return role, nil
+	},
+}
+
+var DeleteRoleMutation = &graphql.Field{
+	Type:        roleType,
+	Description: "역할을 삭제합니다. roles.manage 권한이 필요하며, admin 역할은 삭제할 수 없습니다.",
+	Args: graphql.FieldConfigArgument{
+		"roleID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageRoles) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var role Role
+		database.DB.Where(&Role{ID: params.Args["roleID"].(int)}).First(&role)
+		if role.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		} else if role.Name == AdminRoleName {
+			return nil, fmt.Errorf("ROL001")
+		}
+
+		tx := database.DB.Begin()
+		tx.Where(&RolePermission{RoleID: role.ID}).Delete(RolePermission{})
+		tx.Where(&MemberRole{RoleID: role.ID}).Delete(MemberRole{})
+		tx.Delete(&role)
+		if errs := tx.Commit().GetErrors(); len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return role, nil
+	},
+}
+
+var AssignRoleMutation = &graphql.Field{
+	Type:        memberType,
+	Description: "회원에게 역할을 부여합니다. roles.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"memberUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+		"roleID":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageRoles) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		member, err := GetMemberByUUID(params.Args["memberUUID"].(string))
+		if err != nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+		var role Role
+		database.DB.Where(&Role{ID: params.Args["roleID"].(int)}).First(&role)
+		if role.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		if err := setMemberRole(member, &role, true); err != nil {
+			return nil, err
+		}
+		return member, nil
+	},
+}
+
+var UnassignRoleMutation = &graphql.Field{
+	Type:        memberType,
+	Description: "회원의 역할을 회수합니다. roles.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"memberUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+		"roleID":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageRoles) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		member, err := GetMemberByUUID(params.Args["memberUUID"].(string))
+		if err != nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+		var role Role
+		database.DB.Where(&Role{ID: params.Args["roleID"].(int)}).First(&role)
+		if role.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		if err := setMemberRole(member, &role, false); err != nil {
+			return nil, err
+		}
+		return member, nil
+	},
+}
+
+// Common functions
+func allPermissions() []Permission {
+	var permissions []Permission
+	for name, description := range permissionDescriptions {
+		permissions = append(permissions, Permission{Name: name, Description: description})
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].Name < permissions[j].Name })
+	return permissions
+}
+
+func isPermission(permission string) bool {
+	_, ok := permissionDescriptions[permission]
+	return ok
+}
+
+// isBoardAccess는 게시판의 읽기/쓰기 권한으로 사용할 수 있는 값인지 확인합니다.
+func isBoardAccess(access string) bool {
+	return access == BoardAccessPublic || access == BoardAccessMember || access == BoardAccessGroups || isPermission(access)
+}
+
+func getMemberRoles(memberUUID string) []Role {
+	var roles []Role
+	database.DB.Joins("join member_roles on member_roles.role_id = roles.id").
+		Where("member_roles.member_uuid = ?", memberUUID).Order("roles.id asc").Find(&roles)
+	return roles
+}
+
+func getMemberPermissions(memberUUID string) []string {
+	var permissions []string
+	database.DB.Model(&RolePermission{}).
+		Joins("join member_roles on member_roles.role_id = role_permissions.role_id").
+		Where("member_roles.member_uuid = ?", memberUUID).
+		Pluck("distinct role_permissions.permission", &permissions)
+	return permissions
+}
+
+// saveRole은 역할을 저장하고, permissions가 주어진 경우 역할의 권한을 교체합니다.
+func saveRole(role *Role, permissions interface{}) error {
+	tx := database.DB.Begin()
+	if errs := tx.Save(role).GetErrors(); len(errs) > 0 {
+		tx.Rollback()
+		return errs[0]
+	}
+
+	if permissions != nil {
+		tx.Where(&RolePermission{RoleID: role.ID}).Delete(RolePermission{})
+		for _, p := range permissions.([]interface{}) {
+			if !isPermission(p.(string)) {
+				tx.Rollback()
+				return fmt.Errorf("ERR400")
+			}
+			if errs := tx.Save(&RolePermission{RoleID: role.ID, Permission: p.(string)}).GetErrors(); len(errs) > 0 {
+				tx.Rollback()
+				return errs[0]
+			}
+		}
+	}
+
+	if errs := tx.Commit().GetErrors(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// setMemberRole은 회원에게 역할을 부여하거나 회수합니다.
+// Access token에는 관리자 여부가 담겨 있으므로, admin 역할이 바뀌면 회원의 토큰을 무효화합니다.
+func setMemberRole(member *Member, role *Role, assigned bool) error {
+	tx := database.DB.Begin()
+	memberRole := MemberRole{MemberUUID: member.UUID, RoleID: role.ID}
+	if assigned {
+		tx.Where(memberRole).FirstOrCreate(&memberRole)
+	} else {
+		tx.Where(memberRole).Delete(MemberRole{})
+	}
+
+	if role.Name == AdminRoleName {
+		if err := invalidateMemberTokensWithDB(tx, member); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if errs := tx.Commit().GetErrors(); len(errs) > 0 {
+		return errs[0]
+	}
+	member.grantedPermissions = nil
+	return nil
+}
+
+// invalidateMemberTokensWithDB는 회원의 토큰 버전을 올리고 모든 세션을 폐기합니다.
+func invalidateMemberTokensWithDB(db *gorm.DB, member *Member) error {
+	member.TokenVersion++
+	if err := db.Model(member).UpdateColumn("token_version", member.TokenVersion).Error; err != nil {
+		return err
+	}
+	return revokeAllSessionsWithDB(db, member.UUID)
+}
+
+// migrateRoles는 admin 역할이 모든 권한을 갖도록 합니다.
+// 또한 기존의 관리자 플래그(members.is_admin)를 admin 역할로, 게시판의 ADMIN 권한을 boards.staff 권한으로 옮깁니다.
+func migrateRoles() {
+	var admin Role
+	database.DB.Where(Role{Name: AdminRoleName}).Attrs(Role{Description: "모든 권한을 가진 관리자"}).FirstOrCreate(&admin)
+	for permission := range permissionDescriptions {
+		database.DB.Where(RolePermission{RoleID: admin.ID, Permission: permission}).FirstOrCreate(&RolePermission{})
+	}
+
+	if !database.DB.Dialect().HasColumn("members", "is_admin") {
+		return
+	}
+	var adminUUIDs []string
+	database.DB.Table("members").Where("is_admin = ?", true).Pluck("uuid", &adminUUIDs)
+
+	// Any error aborts the transaction, so the migration is retried on the next start.
+	tx := database.DB.Begin()
+	for _, memberUUID := range adminUUIDs {
+		tx.Where(MemberRole{MemberUUID: memberUUID, RoleID: admin.ID}).FirstOrCreate(&MemberRole{})
+	}
+	for _, column := range []string{"read_permission", "write_permission"} {
+		tx.Model(&Board{}).ModifyColumn(column, "varchar(40)")
+		tx.Model(&Board{}).Where(column+" = ?", "ADMIN").UpdateColumn(column, PermissionAccessStaff)
+		tx.Model(&Board{}).Where(column+" not in (?)", []string{BoardAccessPublic, PermissionAccessStaff}).UpdateColumn(column, BoardAccessMember)
+	}
+	tx.Model(&Member{}).DropColumn("is_admin")
+	tx.Commit()
+}
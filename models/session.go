@@ -0,0 +1,324 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+
+	"nagase/components/auth"
+	"nagase/components/database"
+)
+
+// Session은 한 번의 로그인으로 시작된 refresh token 체인입니다.
+// Refresh token은 갱신할 때마다 교체(rotation)되며, 원문 대신 해시만 저장합니다.
+type Session struct {
+	UUID string `gorm:"type:varchar(40);PRIMARY_KEY"`
+
+	MemberUUID       string `gorm:"type:varchar(40);INDEX"`
+	RefreshTokenHash string `gorm:"type:varchar(64);UNIQUE_INDEX"`
+	ExpiresAt        time.Time
+	RevokedAt        *time.Time
+
+	UserAgent  string `gorm:"type:varchar(255)"`
+	IPAddress  string `gorm:"type:varchar(45)"`
+	LastUsedAt time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// UsedRefreshToken은 교체되어 더 이상 쓸 수 없는 refresh token입니다.
+// 이 토큰이 다시 사용되면 탈취된 것으로 보고 해당 세션을 폐기합니다.
+type UsedRefreshToken struct {
+	TokenHash   string `gorm:"type:varchar(64);PRIMARY_KEY"`
+	SessionUUID string `gorm:"type:varchar(40);INDEX"`
+
+	CreatedAt time.Time
+}
+
+func (session *Session) IsValid() bool {
+	return session.RevokedAt == nil && session.ExpiresAt.After(time.Now())
+}
+
+func (session *Session) Revoke() error {
+	if session.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	session.RevokedAt = &now
+	errs := database.DB.Save(session).GetErrors()
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// Touch는 세션의 마지막 사용 시각과 IP를 갱신합니다.
+// 요청마다 DB에 쓰지 않도록, 1분 이내에 갱신된 세션은 건너뜁니다.
+func (session *Session) Touch(ipAddress string) {
+	if session.IPAddress == ipAddress && time.Since(session.LastUsedAt) < time.Minute {
+		return
+	}
+
+	session.IPAddress = ipAddress
+	session.LastUsedAt = time.Now()
+	database.DB.Model(session).UpdateColumns(map[string]interface{}{
+		"ip_address":   session.IPAddress,
+		"last_used_at": session.LastUsedAt,
+	})
+}
+
+var sessionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Session",
+	Fields: graphql.Fields{
+		"uuid":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"userAgent":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"ipAddress":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"lastUsedAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"expiresAt":  &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"revokedAt":  &graphql.Field{Type: graphql.DateTime},
+		"createdAt":  &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"isCurrent": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.Boolean),
+			Description: "현재 요청에 사용된 세션인지 여부",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				current, ok := params.Context.Value("session").(*Session)
+				return ok && current.UUID == params.Source.(Session).UUID, nil
+			},
+		},
+	},
+})
+
+// Queries
+var MySessionsQuery = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(sessionType))),
+	Description: "자신이 로그인한 세션 목록을 조회합니다. 최근에 사용한 세션부터 반환합니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		return getActiveSessions(member.UUID), nil
+	},
+}
+
+var MemberSessionsQuery = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(sessionType))),
+	Description: "회원이 로그인한 세션 목록을 조회합니다. members.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"memberUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageMembers) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		return getActiveSessions(params.Args["memberUUID"].(string)), nil
+	},
+}
+
+// Mutations
+var RevokeSessionMutation = &graphql.Field{
+	Type:        sessionType,
+	Description: "세션을 폐기합니다. 폐기된 세션의 access token과 refresh token은 더 이상 사용할 수 없습니다. 다른 회원의 세션을 폐기하려면 members.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"sessionUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		var session Session
+		database.DB.Where(&Session{UUID: params.Args["sessionUUID"].(string)}).First(&session)
+		if session.UUID == "" {
+			return nil, fmt.Errorf("ERR400")
+		} else if session.MemberUUID != member.UUID && !member.Can(PermissionManageMembers) {
+			return nil, fmt.Errorf("ERR403")
+		}
+
+		if err := session.Revoke(); err != nil {
+			return nil, err
+		}
+		return session, nil
+	},
+}
+
+var RevokeAllSessionsMutation = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.Int),
+	Description: "모든 세션을 폐기하고, 폐기한 세션의 수를 반환합니다. memberUUID를 지정하면 해당 회원의 세션을 폐기하며, members.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"memberUUID": &graphql.ArgumentConfig{Type: graphql.String},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member := params.Context.Value("member").(*Member)
+
+		memberUUID := member.UUID
+		if params.Args["memberUUID"] != nil {
+			if !member.Can(PermissionManageMembers) {
+				return nil, fmt.Errorf("ERR403")
+			}
+			memberUUID = params.Args["memberUUID"].(string)
+		}
+
+		return revokeAllSessions(memberUUID)
+	},
+}
+
+// Common functions
+
+// createSession은 새로운 세션을 시작하고 access token과 refresh token을 발급합니다.
+// 접속 기기 정보는 HTTP 핸들러가 context에 넣어준 userAgent, ipAddress 값을 사용합니다.
+func createSession(ctx context.Context, member *Member) (*AccessToken, error) {
+	refreshToken, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := Session{
+		UUID:             uuid.NewV4().String(),
+		MemberUUID:       member.UUID,
+		RefreshTokenHash: auth.HashRefreshToken(refreshToken),
+		ExpiresAt:        time.Now().Add(auth.RefreshTokenLifetime),
+		LastUsedAt:       time.Now(),
+	}
+	if userAgent, ok := ctx.Value("userAgent").(string); ok {
+		if len(userAgent) > 255 {
+			userAgent = userAgent[:255]
+		}
+		session.UserAgent = userAgent
+	}
+	if ipAddress, ok := ctx.Value("ipAddress").(string); ok {
+		session.IPAddress = ipAddress
+	}
+	errs := database.DB.Create(&session).GetErrors()
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	// Login finished, so forget the failed attempts of the member.
+	resetLoginAttempts(memberLoginAttemptKey(member.UUID))
+
+	key, err := auth.GenerateToken(member.UUID, session.UUID, member.TokenVersion, member.IsAdmin())
+	if err != nil {
+		return nil, err
+	}
+
+	return &AccessToken{Key: key, RefreshToken: refreshToken}, nil
+}
+
+// rotateSession은 refresh token을 새 토큰으로 교체하고 새 access token을 발급합니다.
+// 이미 교체된 refresh token이 다시 사용되면 세션 전체를 폐기합니다.
+func rotateSession(ctx context.Context, refreshToken string) (*AccessToken, error) {
+	hash := auth.HashRefreshToken(refreshToken)
+
+	var session Session
+	database.DB.Where(&Session{RefreshTokenHash: hash}).First(&session)
+	if session.UUID == "" {
+		// Detect reuse of a rotated refresh token.
+		var used UsedRefreshToken
+		database.DB.Where(&UsedRefreshToken{TokenHash: hash}).First(&used)
+		if used.SessionUUID == "" {
+			return nil, fmt.Errorf("TKN003")
+		}
+
+		database.DB.Where(&Session{UUID: used.SessionUUID}).First(&session)
+		if session.UUID != "" {
+			session.Revoke()
+		}
+		return nil, fmt.Errorf("TKN004")
+	}
+	if !session.IsValid() {
+		return nil, fmt.Errorf("TKN003")
+	}
+
+	member, err := GetMemberByUUID(session.MemberUUID)
+	if err != nil || !member.IsActivated {
+		return nil, fmt.Errorf("TKN003")
+	}
+
+	newRefreshToken, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := database.DB.Begin()
+	errs := tx.Create(&UsedRefreshToken{TokenHash: hash, SessionUUID: session.UUID}).GetErrors()
+	if len(errs) > 0 {
+		tx.Rollback()
+		return nil, errs[0]
+	}
+	session.RefreshTokenHash = auth.HashRefreshToken(newRefreshToken)
+	session.ExpiresAt = time.Now().Add(auth.RefreshTokenLifetime)
+	session.LastUsedAt = time.Now()
+	if ipAddress, ok := ctx.Value("ipAddress").(string); ok {
+		session.IPAddress = ipAddress
+	}
+	errs = tx.Save(&session).GetErrors()
+	if len(errs) > 0 {
+		tx.Rollback()
+		return nil, errs[0]
+	}
+	if errs = tx.Commit().GetErrors(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	key, err := auth.GenerateToken(member.UUID, session.UUID, member.TokenVersion, member.IsAdmin())
+	if err != nil {
+		return nil, err
+	}
+
+	return &AccessToken{Key: key, RefreshToken: newRefreshToken}, nil
+}
+
+func getActiveSessions(memberUUID string) []Session {
+	var sessions []Session
+	database.DB.Where("member_uuid = ? and revoked_at is null and expires_at > ?", memberUUID, time.Now()).Order("last_used_at desc").Find(&sessions)
+	return sessions
+}
+
+func revokeAllSessions(memberUUID string) (int, error) {
+	now := time.Now()
+	query := database.DB.Model(&Session{}).Where("member_uuid = ? and revoked_at is null", memberUUID).Update("revoked_at", now)
+	if query.Error != nil {
+		return 0, query.Error
+	}
+	return int(query.RowsAffected), nil
+}
+
+// revokeAllSessionsWithDB는 revokeAllSessions와 같지만, 주어진 트랜잭션 안에서 실행합니다.
+func revokeAllSessionsWithDB(db *gorm.DB, memberUUID string) error {
+	return db.Model(&Session{}).Where("member_uuid = ? and revoked_at is null", memberUUID).Update("revoked_at", time.Now()).Error
+}
+
+// GetMemberByAccessToken은 access token을 검증하고, 토큰의 회원과 세션을 반환합니다.
+// 세션이 폐기되었거나, 회원이 비활성화되었거나, 토큰 발급 이후 회원의 토큰 버전이 바뀐 경우 오류를 반환합니다.
+func GetMemberByAccessToken(tokenString string) (*Member, *Session, error) {
+	claims, err := auth.ValidatedToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var session Session
+	database.DB.Where(&Session{UUID: claims.SessionUUID}).First(&session)
+	if session.UUID == "" || session.MemberUUID != claims.MemberUUID || session.RevokedAt != nil {
+		return nil, nil, fmt.Errorf("invalid session")
+	}
+
+	member, err := GetMemberByUUID(claims.MemberUUID)
+	if err != nil || !member.IsActivated || member.TokenVersion != claims.TokenVersion {
+		return nil, nil, fmt.Errorf("invalid member")
+	}
+
+	return member, &session, nil
+}
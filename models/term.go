@@ -0,0 +1,510 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/jinzhu/gorm"
+
+	"nagase/components/database"
+)
+
+// 학기별 회원 상태
+const (
+	EnrollmentStatusActive  = "ACTIVE"
+	EnrollmentStatusOnLeave = "ON_LEAVE"
+	EnrollmentStatusAlumni  = "ALUMNI"
+)
+
+// 회비 납부 방법
+const (
+	DuesMethodCash     = "CASH"
+	DuesMethodTransfer = "TRANSFER"
+	DuesMethodCard     = "CARD"
+	DuesMethodOther    = "OTHER"
+)
+
+// Term은 회원 활동 기간의 단위인 학기입니다.
+type Term struct {
+	ID int
+
+	Name       string `gorm:"type:varchar(40);UNIQUE_INDEX"` // e.g. 2019-1
+	StartsAt   time.Time
+	EndsAt     time.Time
+	DuesAmount int
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TermEnrollment는 학기별 회원 등록 정보입니다.
+type TermEnrollment struct {
+	TermID     int    `gorm:"PRIMARY_KEY;AUTO_INCREMENT:false"`
+	MemberUUID string `gorm:"type:varchar(40);PRIMARY_KEY"`
+	Status     string `gorm:"type:varchar(20)"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// DuesPayment는 회비 납부 기록입니다.
+type DuesPayment struct {
+	ID int
+
+	TermID     int    `gorm:"INDEX"`
+	MemberUUID string `gorm:"type:varchar(40);INDEX"`
+	Amount     int
+	PaidAt     time.Time
+	Method     string `gorm:"type:varchar(20)"`
+	Note       string `gorm:"type:varchar(255)"`
+
+	RecordedByUUID string `gorm:"type:varchar(40)"`
+	CreatedAt      time.Time
+}
+
+// 학기가 끝난 뒤 다음 학기가 시작되기 전까지, 등록된 회원이 계속 접근할 수 있는 기간
+const termRenewalGracePeriod = 14 * 24 * time.Hour
+
+// hasActiveMembership은 회원이 현재 학기에 등록(활동 또는 휴학)되어 있는지 확인합니다.
+// 현재 학기는 이미 시작한 학기 중 가장 최근 학기이며, 학기가 끝나고 termRenewalGracePeriod가 지나도록 다음 학기가 시작되지 않으면
+// 아무도 등록되지 않은 것으로 봅니다. 다음 학기에 미리 등록한 회원은 등록된 것으로 봅니다.
+// 학기가 하나도 없거나, members.manage 또는 terms.manage 권한이 있는 회원은 등록된 것으로 봅니다.
+func (member *Member) hasActiveMembership() bool {
+	if member == nil {
+		return false
+	}
+	if member.activeMembership == nil {
+		active := true
+		isManager := member.Can(PermissionManageMembers) || member.Can(PermissionManageTerms)
+		if term := getCurrentTerm(); term != nil && !isManager {
+			active = time.Now().Before(term.EndsAt.Add(termRenewalGracePeriod)) && isEnrolledInTerm(term.ID, member.UUID)
+			if next := getNextTerm(); !active && next != nil {
+				active = isEnrolledInTerm(next.ID, member.UUID)
+			}
+		}
+		member.activeMembership = &active
+	}
+	return *member.activeMembership
+}
+
+// enrollInCurrentTermWithDB는 새로 활성화된 회원을 진행 중인 학기에 활동 회원으로 등록합니다. 이미 등록된 경우 그대로 둡니다.
+func enrollInCurrentTermWithDB(db *gorm.DB, memberUUID string) error {
+	term := getCurrentTerm()
+	if term == nil || !time.Now().Before(term.EndsAt) {
+		return nil
+	}
+
+	var enrollment TermEnrollment
+	db.Where("term_id = ? AND member_uuid = ?", term.ID, memberUUID).First(&enrollment)
+	if enrollment.TermID != 0 {
+		return nil
+	}
+	return db.Create(&TermEnrollment{TermID: term.ID, MemberUUID: memberUUID, Status: EnrollmentStatusActive}).Error
+}
+
+var termType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Term",
+	Fields: graphql.Fields{
+		"id":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"name":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"startsAt":   &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"endsAt":     &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"duesAmount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var termEnrollmentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "TermEnrollment",
+	Fields: graphql.Fields{
+		"term": &graphql.Field{
+			Type: graphql.NewNonNull(termType),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				var term Term
+				database.DB.Where(&Term{ID: params.Source.(TermEnrollment).TermID}).First(&term)
+				return term, nil
+			},
+		},
+		"status": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"paidAmount": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.Int),
+			Description: "해당 학기에 납부한 회비 합계",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				enrollment := params.Source.(TermEnrollment)
+				return getPaidDuesAmount(enrollment.TermID, enrollment.MemberUUID), nil
+			},
+		},
+	},
+})
+
+func init() {
+	termEnrollmentType.AddFieldConfig("member", &graphql.Field{
+		Type: memberType,
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			member, _ := GetMemberByUUID(params.Source.(TermEnrollment).MemberUUID)
+			return member, nil
+		},
+	})
+}
+
+var duesPaymentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "DuesPayment",
+	Fields: graphql.Fields{
+		"id": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"term": &graphql.Field{
+			Type: graphql.NewNonNull(termType),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				var term Term
+				database.DB.Where(&Term{ID: params.Source.(DuesPayment).TermID}).First(&term)
+				return term, nil
+			},
+		},
+		"memberUUID": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"amount":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"paidAt":     &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"method":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"note":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var termInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "TermInput",
+	Description: "학기 추가/수정 InputObject",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"startsAt":   &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		"endsAt":     &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		"duesAmount": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+	},
+})
+
+var duesPaymentInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "DuesPaymentInput",
+	Description: "회비 납부 기록 InputObject",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"termID":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"memberUUID": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"amount":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"paidAt":     &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		"method": &graphql.InputObjectFieldConfig{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "CASH, TRANSFER, CARD 또는 OTHER",
+		},
+		"note": &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+// Queries
+var TermsQuery = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(termType))),
+	Description: "학기 목록을 조회합니다.",
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var terms []Term
+		database.DB.Order("starts_at desc").Find(&terms)
+		return terms, nil
+	},
+}
+
+var TermEnrollmentsQuery = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(termEnrollmentType))),
+	Description: "학기에 등록된 회원 목록을 조회합니다. terms.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"termID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"status": &graphql.ArgumentConfig{Type: graphql.String},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageTerms) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		query := &TermEnrollment{TermID: params.Args["termID"].(int)}
+		if status, ok := params.Args["status"].(string); ok {
+			query.Status = status
+		}
+
+		var enrollments []TermEnrollment
+		database.DB.Where(query).Order("created_at asc").Find(&enrollments)
+		return enrollments, nil
+	},
+}
+
+var DuesPaymentsQuery = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(duesPaymentType))),
+	Description: "학기의 회비 납부 기록을 조회합니다. terms.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"termID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageTerms) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var payments []DuesPayment
+		database.DB.Where(&DuesPayment{TermID: params.Args["termID"].(int)}).Order("paid_at asc").Find(&payments)
+		return payments, nil
+	},
+}
+
+// Mutations
+var CreateTermMutation = &graphql.Field{
+	Type:        termType,
+	Description: "학기를 추가합니다. terms.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"TermInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(termInputType)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageTerms) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		input := params.Args["TermInput"].(map[string]interface{})
+		if input["name"] == nil || input["startsAt"] == nil || input["endsAt"] == nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		var term Term
+		return saveTerm(&term, input)
+	},
+}
+
+var UpdateTermMutation = &graphql.Field{
+	Type:        termType,
+	Description: "학기를 수정합니다. terms.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"termID":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"TermInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(termInputType)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageTerms) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var term Term
+		database.DB.Where(&Term{ID: params.Args["termID"].(int)}).First(&term)
+		if term.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+		return saveTerm(&term, params.Args["TermInput"].(map[string]interface{}))
+	},
+}
+
+var EnrollMemberMutation = &graphql.Field{
+	Type:        termEnrollmentType,
+	Description: "회원을 학기에 등록하거나, 등록된 회원의 상태를 바꿉니다. terms.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"termID":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"memberUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+		"status": &graphql.ArgumentConfig{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "ACTIVE, ON_LEAVE 또는 ALUMNI",
+		},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageTerms) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var term Term
+		database.DB.Where(&Term{ID: params.Args["termID"].(int)}).First(&term)
+		if term.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+		member, err := GetMemberByUUID(params.Args["memberUUID"].(string))
+		if err != nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		status := params.Args["status"].(string)
+		if status != EnrollmentStatusActive && status != EnrollmentStatusOnLeave && status != EnrollmentStatusAlumni {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		enrollment := TermEnrollment{TermID: term.ID, MemberUUID: member.UUID}
+		database.DB.Where(&enrollment).First(&enrollment)
+		enrollment.Status = status
+		errs := database.DB.Save(&enrollment).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return enrollment, nil
+	},
+}
+
+var UnenrollMemberMutation = &graphql.Field{
+	Type:        termEnrollmentType,
+	Description: "학기 등록을 취소합니다. terms.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"termID":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"memberUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageTerms) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var enrollment TermEnrollment
+		database.DB.Where(&TermEnrollment{TermID: params.Args["termID"].(int), MemberUUID: params.Args["memberUUID"].(string)}).First(&enrollment)
+		if enrollment.TermID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		errs := database.DB.Delete(&enrollment).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return enrollment, nil
+	},
+}
+
+var RecordDuesPaymentMutation = &graphql.Field{
+	Type:        duesPaymentType,
+	Description: "회비 납부를 기록합니다. terms.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"DuesPaymentInput": &graphql.ArgumentConfig{Type: graphql.NewNonNull(duesPaymentInputType)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageTerms) {
+			return nil, fmt.Errorf("ERR401")
+		}
+		recorder := params.Context.Value("member").(*Member)
+
+		input := params.Args["DuesPaymentInput"].(map[string]interface{})
+		var term Term
+		database.DB.Where(&Term{ID: input["termID"].(int)}).First(&term)
+		if term.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+		member, err := GetMemberByUUID(input["memberUUID"].(string))
+		if err != nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		payment := DuesPayment{
+			TermID:         term.ID,
+			MemberUUID:     member.UUID,
+			Amount:         input["amount"].(int),
+			PaidAt:         time.Now(),
+			Method:         input["method"].(string),
+			RecordedByUUID: recorder.UUID,
+		}
+		if input["paidAt"] != nil {
+			payment.PaidAt = input["paidAt"].(time.Time)
+		}
+		if input["note"] != nil {
+			payment.Note = input["note"].(string)
+		}
+		if payment.Amount <= 0 || !isDuesMethod(payment.Method) {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		errs := database.DB.Create(&payment).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return payment, nil
+	},
+}
+
+var DeleteDuesPaymentMutation = &graphql.Field{
+	Type:        duesPaymentType,
+	Description: "잘못 입력한 회비 납부 기록을 삭제합니다. terms.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"duesPaymentID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageTerms) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		var payment DuesPayment
+		database.DB.Where(&DuesPayment{ID: params.Args["duesPaymentID"].(int)}).First(&payment)
+		if payment.ID == 0 {
+			return nil, fmt.Errorf("ERR400")
+		}
+
+		errs := database.DB.Delete(&payment).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return payment, nil
+	},
+}
+
+// Common functions
+
+// getCurrentTerm은 이미 시작한 학기 중 가장 최근 학기를 반환합니다.
+func getCurrentTerm() *Term {
+	var term Term
+	database.DB.Where("starts_at <= ?", time.Now()).Order("starts_at desc").First(&term)
+	if term.ID == 0 {
+		return nil
+	}
+	return &term
+}
+
+// getNextTerm은 아직 시작하지 않은 학기 중 가장 먼저 시작하는 학기를 반환합니다.
+func getNextTerm() *Term {
+	var term Term
+	database.DB.Where("starts_at > ?", time.Now()).Order("starts_at asc").First(&term)
+	if term.ID == 0 {
+		return nil
+	}
+	return &term
+}
+
+// isEnrolledInTerm은 회원이 학기에 활동 또는 휴학으로 등록되어 있는지 확인합니다.
+func isEnrolledInTerm(termID int, memberUUID string) bool {
+	var enrollment TermEnrollment
+	database.DB.Where("term_id = ? AND member_uuid = ?", termID, memberUUID).First(&enrollment)
+	return enrollment.Status == EnrollmentStatusActive || enrollment.Status == EnrollmentStatusOnLeave
+}
+
+func getPaidDuesAmount(termID int, memberUUID string) int {
+	var result struct{ Total int }
+	database.DB.Model(&DuesPayment{}).Select("COALESCE(SUM(amount), 0) AS total").
+		Where(&DuesPayment{TermID: termID, MemberUUID: memberUUID}).Scan(&result)
+	return result.Total
+}
+
+func isDuesMethod(method string) bool {
+	switch method {
+	case DuesMethodCash, DuesMethodTransfer, DuesMethodCard, DuesMethodOther:
+		return true
+	}
+	return false
+}
+
+func saveTerm(term *Term, input map[string]interface{}) (interface{}, error) {
+	if input["name"] != nil {
+		term.Name = strings.TrimSpace(input["name"].(string))
+	}
+	if input["startsAt"] != nil {
+		term.StartsAt = input["startsAt"].(time.Time)
+	}
+	if input["endsAt"] != nil {
+		term.EndsAt = input["endsAt"].(time.Time)
+	}
+	if input["duesAmount"] != nil {
+		term.DuesAmount = input["duesAmount"].(int)
+	}
+	if term.Name == "" || !term.StartsAt.Before(term.EndsAt) || term.DuesAmount < 0 {
+		return nil, fmt.Errorf("ERR400")
+	}
+
+	var duplicated Term
+	database.DB.Where(&Term{Name: term.Name}).First(&duplicated)
+	if duplicated.ID != 0 && duplicated.ID != term.ID {
+		return nil, fmt.Errorf("ERR400")
+	}
+
+	errs := database.DB.Save(term).GetErrors()
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return *term, nil
+}
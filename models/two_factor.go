@@ -0,0 +1,375 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+
+	"nagase/components/auth"
+	"nagase/components/database"
+	"nagase/components/totp"
+)
+
+// 로그인 2단계 인증에 사용하는 challenge token의 용도와 유효 기간
+const (
+	challengePurposeTOTPLogin  = "totp-login"
+	challengePurposeTOTPEnroll = "totp-enroll"
+	challengeTokenLifetime     = 5 * time.Minute
+
+	recoveryCodesCount = 10
+)
+
+// RecoveryCode는 OTP 기기를 잃어버렸을 때 한 번 사용할 수 있는 복구 코드입니다. 원문 대신 해시만 저장합니다.
+type RecoveryCode struct {
+	ID int
+
+	MemberUUID string `gorm:"type:varchar(40);INDEX"`
+	CodeHash   string `gorm:"type:varchar(64)"`
+	UsedAt     *time.Time
+
+	CreatedAt time.Time
+}
+
+// UsedChallengeToken은 이미 로그인에 사용되어 다시 쓸 수 없는 challenge token입니다. 원문 대신 해시만 저장합니다.
+type UsedChallengeToken struct {
+	TokenHash string `gorm:"type:varchar(64);PRIMARY_KEY"`
+	ExpiresAt time.Time
+
+	CreatedAt time.Time
+}
+
+type TOTPEnrollment struct {
+	Secret          string
+	ProvisioningURI string `json:"provisioningURI"`
+}
+
+var totpEnrollmentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "TOTPEnrollment",
+	Fields: graphql.Fields{
+		"secret":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"provisioningURI": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+type TOTPConfirmation struct {
+	RecoveryCodes []string
+	AccessToken   *AccessToken
+}
+
+var totpConfirmationType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "TOTPConfirmation",
+	Fields: graphql.Fields{
+		"recoveryCodes": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+		"accessToken": &graphql.Field{
+			Type:        accessTokenType,
+			Description: "challengeToken으로 등록한 경우, 로그인을 마친 Access Token",
+		},
+	},
+})
+
+// Mutations
+var EnrollTOTPMutation = &graphql.Field{
+	Type:        totpEnrollmentType,
+	Description: "OTP 등록을 시작합니다. 반환된 provisioningURI를 OTP 앱에 등록한 뒤 confirmTOTP mutation으로 등록을 마쳐야 합니다. 2단계 인증 등록이 필요한 회원은 로그인 시 받은 challengeToken으로 등록할 수 있습니다.",
+	Args: graphql.FieldConfigArgument{
+		"challengeToken": &graphql.ArgumentConfig{Type: graphql.String},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		member, err := getTOTPEnrollingMember(params)
+		if err != nil {
+			return nil, err
+		}
+		if member.IsTOTPEnabled {
+			return nil, fmt.Errorf("TFA000")
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			return nil, fmt.Errorf("ERR500")
+		}
+		errs := database.DB.Model(member).UpdateColumn("totp_secret", secret).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+
+		return TOTPEnrollment{Secret: secret, ProvisioningURI: totp.ProvisioningURI(secret, member.LoginID)}, nil
+	},
+}
+
+var ConfirmTOTPMutation = &graphql.Field{
+	Type:        totpConfirmationType,
+	Description: "OTP 코드를 확인하여 2단계 인증 등록을 마치고, 복구 코드를 발급합니다. 복구 코드는 이 응답에서만 확인할 수 있습니다.",
+	Args: graphql.FieldConfigArgument{
+		"code":           &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+		"challengeToken": &graphql.ArgumentConfig{Type: graphql.String},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		member, err := getTOTPEnrollingMember(params)
+		if err != nil {
+			return nil, err
+		}
+		if member.IsTOTPEnabled || member.TOTPSecret == "" {
+			return nil, fmt.Errorf("TFA000")
+		}
+		if !member.useTOTPCode(params.Args["code"].(string)) {
+			return nil, fmt.Errorf("TFA001")
+		}
+
+		member.IsTOTPEnabled = true
+		errs := database.DB.Model(member).UpdateColumn("is_totp_enabled", true).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+
+		codes, err := member.regenerateRecoveryCodes()
+		if err != nil {
+			return nil, fmt.Errorf("ERR500")
+		}
+		confirmation := TOTPConfirmation{RecoveryCodes: codes}
+
+		// Finish login if the member was enrolling with a challenge token.
+		if params.Args["challengeToken"] != nil {
+			confirmation.AccessToken, err = createSession(params.Context, member)
+			if err != nil {
+				return nil, fmt.Errorf("ERR500")
+			}
+		}
+		return confirmation, nil
+	},
+}
+
+var DisableTOTPMutation = &graphql.Field{
+	Type:        memberType,
+	Description: "2단계 인증을 해제합니다. 비밀번호 확인이 필요하며, 관리자가 2단계 인증을 요구한 회원은 해제할 수 없습니다.",
+	Args: graphql.FieldConfigArgument{
+		"password": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member, _ := GetMemberByUUID(params.Context.Value("member").(*Member).UUID)
+
+		if member.IsTOTPRequired {
+			return nil, fmt.Errorf("ERR403")
+		} else if !member.ValidatePassword(params.Args["password"].(string)) {
+			return nil, fmt.Errorf("TKN000")
+		}
+
+		member.TOTPSecret = ""
+		member.IsTOTPEnabled = false
+		errs := database.DB.Save(member).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		database.DB.Where(&RecoveryCode{MemberUUID: member.UUID}).Delete(RecoveryCode{})
+
+		return member, nil
+	},
+}
+
+var RegenerateRecoveryCodesMutation = &graphql.Field{
+	Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+	Description: "복구 코드를 새로 발급합니다. 기존 복구 코드는 더 이상 사용할 수 없습니다.",
+	Args: graphql.FieldConfigArgument{
+		"code": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if params.Context.Value("member") == nil {
+			return nil, fmt.Errorf("ERR401")
+		}
+		member, _ := GetMemberByUUID(params.Context.Value("member").(*Member).UUID)
+
+		if !member.IsTOTPEnabled {
+			return nil, fmt.Errorf("ERR400")
+		} else if !member.useTOTPCode(params.Args["code"].(string)) {
+			return nil, fmt.Errorf("TFA001")
+		}
+
+		codes, err := member.regenerateRecoveryCodes()
+		if err != nil {
+			return nil, fmt.Errorf("ERR500")
+		}
+		return codes, nil
+	},
+}
+
+var VerifyTwoFactorMutation = &graphql.Field{
+	Type:        accessTokenType,
+	Description: "로그인 2단계 인증을 진행합니다. createAccessToken mutation에서 받은 challengeToken과 OTP 코드 또는 복구 코드가 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"challengeToken": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+		"code": &graphql.ArgumentConfig{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "OTP 앱의 6자리 코드 또는 복구 코드",
+		},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		member, err := getMemberByChallengeToken(params.Args["challengeToken"].(string), challengePurposeTOTPLogin)
+		if err != nil {
+			return nil, err
+		}
+
+		// Failed codes count towards the same lockout as failed passwords.
+		ipAddress, _ := params.Context.Value("ipAddress").(string)
+		if err := checkMemberLoginAllowed(member, ipAddress); err != nil {
+			return nil, err
+		}
+
+		code := params.Args["code"].(string)
+		if !member.useTOTPCode(code) && !member.useRecoveryCode(code) {
+			recordMemberLoginFailure(member, ipAddress)
+			return nil, fmt.Errorf("TFA001")
+		}
+		if err := useChallengeToken(params.Args["challengeToken"].(string)); err != nil {
+			return nil, err
+		}
+
+		token, err := createSession(params.Context, member)
+		if err != nil {
+			return nil, fmt.Errorf("ERR500")
+		}
+		return *token, nil
+	},
+}
+
+var SetMemberTOTPRequiredMutation = &graphql.Field{
+	Type:        memberType,
+	Description: "회원에게 2단계 인증을 요구하거나, 요구를 해제합니다. members.manage 권한이 필요합니다.",
+	Args: graphql.FieldConfigArgument{
+		"memberUUID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+		"isRequired": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Boolean)},
+	},
+	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+		if member := params.Context.Value("member"); member == nil || !member.(*Member).Can(PermissionManageMembers) {
+			return nil, fmt.Errorf("ERR401")
+		}
+
+		member, err := GetMemberByUUID(params.Args["memberUUID"].(string))
+		if err != nil {
+			return nil, fmt.Errorf("ERR400")
+		}
+		member.IsTOTPRequired = params.Args["isRequired"].(bool)
+		errs := database.DB.Save(member).GetErrors()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		return member, nil
+	},
+}
+
+// Common functions
+
+// issueLoginToken은 비밀번호 확인을 마친 회원에게 토큰을 발급합니다.
+// 2단계 인증을 사용하거나 등록해야 하는 회원에게는 access token 대신 challenge token을 발급합니다.
+func issueLoginToken(ctx context.Context, member *Member) (*AccessToken, error) {
+	if member.IsTOTPEnabled || member.IsTOTPRequired {
+		purpose := challengePurposeTOTPLogin
+		if !member.IsTOTPEnabled {
+			purpose = challengePurposeTOTPEnroll
+		}
+
+		challenge, err := auth.GenerateChallengeToken(member.UUID, purpose, nil, challengeTokenLifetime)
+		if err != nil {
+			return nil, err
+		}
+		return &AccessToken{
+			ChallengeToken:                challenge,
+			IsTwoFactorRequired:           member.IsTOTPEnabled,
+			IsTwoFactorEnrollmentRequired: !member.IsTOTPEnabled,
+		}, nil
+	}
+
+	return createSession(ctx, member)
+}
+
+func getMemberByChallengeToken(tokenString string, purpose string) (*Member, error) {
+	claims, err := auth.ValidatedChallengeToken(tokenString, purpose)
+	if err != nil {
+		return nil, fmt.Errorf("TFA002")
+	}
+
+	member, err := GetMemberByUUID(claims.MemberUUID)
+	if err != nil || !member.IsActivated {
+		return nil, fmt.Errorf("TFA002")
+	}
+	return member, nil
+}
+
+// useChallengeToken은 challenge token을 사용한 것으로 기록합니다. 이미 사용된 토큰이면 TFA002를 반환합니다.
+func useChallengeToken(tokenString string) error {
+	database.DB.Where("expires_at < ?", time.Now()).Delete(UsedChallengeToken{})
+
+	used := UsedChallengeToken{TokenHash: auth.HashRefreshToken(tokenString), ExpiresAt: time.Now().Add(challengeTokenLifetime)}
+	if errs := database.DB.Create(&used).GetErrors(); len(errs) > 0 {
+		return fmt.Errorf("TFA002")
+	}
+	return nil
+}
+
+// getTOTPEnrollingMember는 로그인한 회원, 또는 등록용 challenge token의 회원을 반환합니다.
+func getTOTPEnrollingMember(params graphql.ResolveParams) (*Member, error) {
+	if params.Args["challengeToken"] != nil {
+		return getMemberByChallengeToken(params.Args["challengeToken"].(string), challengePurposeTOTPEnroll)
+	}
+
+	if params.Context.Value("member") == nil {
+		return nil, fmt.Errorf("ERR401")
+	}
+	return GetMemberByUUID(params.Context.Value("member").(*Member).UUID)
+}
+
+// useTOTPCode는 OTP 코드를 확인하고, 같은 코드가 다시 사용되지 않도록 사용한 시간 단위를 기록합니다.
+func (member *Member) useTOTPCode(code string) bool {
+	if member.TOTPSecret == "" {
+		return false
+	}
+
+	step, ok := totp.Validate(member.TOTPSecret, code, time.Now())
+	if !ok || step <= member.TOTPLastUsedStep {
+		return false
+	}
+
+	member.TOTPLastUsedStep = step
+	database.DB.Model(member).UpdateColumn("totp_last_used_step", step)
+	return true
+}
+
+// useRecoveryCode는 사용하지 않은 복구 코드를 사용한 것으로 기록합니다. 동시에 같은 코드를 사용해도 한 번만 성공합니다.
+func (member *Member) useRecoveryCode(code string) bool {
+	result := database.DB.Model(&RecoveryCode{}).
+		Where("member_uuid = ? and code_hash = ? and used_at is null", member.UUID, hashRecoveryCode(code)).
+		UpdateColumn("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+func (member *Member) regenerateRecoveryCodes() ([]string, error) {
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := database.DB.Begin()
+	tx.Where(&RecoveryCode{MemberUUID: member.UUID}).Delete(RecoveryCode{})
+	for _, code := range codes {
+		errs := tx.Create(&RecoveryCode{MemberUUID: member.UUID, CodeHash: hashRecoveryCode(code)}).GetErrors()
+		if len(errs) > 0 {
+			tx.Rollback()
+			return nil, errs[0]
+		}
+	}
+	if errs := tx.Commit().GetErrors(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return codes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(hash[:])
+}
@@ -80,7 +80,7 @@ var voteOptionType = graphql.NewObject(graphql.ObjectConfig{
 			},
 		},
 		"voters": &graphql.Field{
-			Type: graphql.NewList(graphql.NewNonNull(memberType)),
+			Type: graphql.NewList(graphql.NewNonNull(publicMemberType)),
 			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
 				var voters []VoteSelection
 				option := params.Source.(VoteOption)
@@ -88,8 +88,7 @@ var voteOptionType = graphql.NewObject(graphql.ObjectConfig{
 
 				var members []Member
 				for _, v := range voters {
-					member, _ := GetMemberByUUID(v.MemberUUID)
-					members = append(members, *member)
+					members = append(members, *getPublicMember(v.MemberUUID))
 				}
 				return members, nil
 			},
@@ -111,12 +110,12 @@ var voteInputType = graphql.NewInputObject(graphql.InputObjectConfig{
 // Queries
 var VoteQuery = &graphql.Field{
 	Type:        voteType,
-	Description: "투표를 조회합니다.",
+	Description: "투표를 조회합니다. 투표가 달린 게시물을 볼 수 있어야 합니다.",
 	Args: graphql.FieldConfigArgument{
 		"voteID": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if params.Context.Value("member") == nil {
+		if requestMember(params.Context, scopePostsRead) == nil {
 			return nil, fmt.Errorf("ERR401")
 		}
 
@@ -126,6 +125,8 @@ var VoteQuery = &graphql.Field{
 		database.DB.Where(&Vote{ID: voteID}).First(&vote)
 		if vote.ID == 0 {
 			return nil, fmt.Errorf("ERR400")
+		} else if !requestMember(params.Context, scopePostsRead).canReadVote(&vote) {
+			return nil, fmt.Errorf("ERR403")
 		}
 		return vote, nil
 	},
@@ -140,16 +141,18 @@ var SelectVoteOptionMutation = &graphql.Field{
 		"optionIDs": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.Int)))},
 	},
 	Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-		if params.Context.Value("member") == nil {
+		member := requestMember(params.Context, scopePostsWrite)
+		if member == nil {
 			return nil, fmt.Errorf("ERR401")
 		}
-		member := params.Context.Value("member").(*Member)
 
 		voteID := params.Args["voteID"].(int)
 		var vote Vote
 		database.DB.Where(&Vote{ID: voteID}).First(&vote)
 		if vote.ID == 0 {
 			return nil, fmt.Errorf("ERR400")
+		} else if !member.canReadVote(&vote) {
+			return nil, fmt.Errorf("ERR403")
 		}
 
 		// Validate vote and selection(s).
@@ -169,3 +172,10 @@ var SelectVoteOptionMutation = &graphql.Field{
 		return vote, nil
 	},
 }
+
+// canReadVote는 투표가 달린 게시물을 볼 수 있는지 확인합니다.
+func (member *Member) canReadVote(vote *Vote) bool {
+	var post Post
+	database.DB.Where("vote_id = ?", vote.ID).First(&post)
+	return post.ID != 0 && member.canReadPost(&post)
+}
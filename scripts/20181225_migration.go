@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"nagase/components/database"
+	"nagase/components/password"
 	"nagase/models"
 )
 
@@ -47,18 +48,24 @@ func migrateMembers() {
 			email = "dummy" + src[0] + "@dummy.com"
 		}
 
+		// Yuzuki stored passwords as bcrypt hashes, which are upgraded on the first login.
+		hashedPassword := ""
+		if password.IsSupported(src[2]) {
+			hashedPassword = src[2]
+		}
+
 		database.DB.Save(&models.Member{
-			UUID:        idToUUID(src[0]),
-			LoginID:     src[1],
-			Email:       "_" + email, // Add underscore to prevent duplication with the Nagase account.
-			PhoneNumber: src[7],
-			Name:        src[4],
-			Department:  "",
-			StudentID:   strings.Repeat("0", 10-len(src[0])) + src[0],
-			IsActivated: false,
-			IsAdmin:     false,
-			CreatedAt:   parseTime(src[1]),
-			UpdatedAt:   time.Now(),
+			UUID:           idToUUID(src[0]),
+			LoginID:        src[1],
+			HashedPassword: hashedPassword,
+			Email:          "_" + email, // Add underscore to prevent duplication with the Nagase account.
+			PhoneNumber:    src[7],
+			Name:           src[4],
+			Department:     "",
+			StudentID:      strings.Repeat("0", 10-len(src[0])) + src[0],
+			IsActivated:    false,
+			CreatedAt:      parseTime(src[1]),
+			UpdatedAt:      time.Now(),
 		})
 	}
 }